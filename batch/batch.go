@@ -0,0 +1,196 @@
+package batch
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+
+	baterror "github.com/harry-hov/alien-invasion/error"
+	"github.com/harry-hov/alien-invasion/invasion"
+	"github.com/harry-hov/alien-invasion/worldmap"
+)
+
+// Options holds the parameters of a batch of invasions
+type Options struct {
+	// Aliens is the number of aliens unleashed in every run
+	Aliens uint
+	// Runs is the number of independent invasions
+	Runs int
+	// Seed of the first run, run n is seeded with Seed+n
+	Seed int64
+	// Workers is the number of parallel runs (default: number of CPUs)
+	Workers int
+	// Config holds the rules of every invasion
+	Config invasion.Config
+}
+
+// Result is the outcome of a single run
+type Result struct {
+	Seed       int64               `json:"seed"`
+	Conclusion invasion.Conclusion `json:"conclusion"`
+	Destroyed  []worldmap.City     `json:"destroyed"`
+	Survivors  int                 `json:"survivors"`
+}
+
+// destructionSink collects the cities destroyed in a run
+type destructionSink struct {
+	invasion.NopSink
+	destroyed []worldmap.City
+}
+
+func (s *destructionSink) CityDestroyed(d invasion.Destruction) {
+	s.destroyed = append(s.destroyed, d.City)
+}
+
+// RunOne plays a single invasion on worldMap seeded with seed
+func RunOne(worldMap *worldmap.WorldMap, seed int64, opts Options) (Result, error) {
+	sink := &destructionSink{}
+	in, err := invasion.InitInvasion(worldMap, opts.Aliens, opts.Config, rand.NewSource(seed), sink)
+	if err != nil {
+		return Result{}, err
+	}
+	for !in.IsFinished() {
+		in.MakeMove()
+		in.Fight()
+	}
+	return Result{
+		Seed:       seed,
+		Conclusion: in.Conclusion(),
+		Destroyed:  sink.destroyed,
+		Survivors:  len(in.GetWorldMap().GetAlienList()),
+	}, nil
+}
+
+// Run plays opts.Runs independent invasions in parallel, each on
+// a clone of worldMap, and returns their statistics.
+// Results only depend on the options, not on the number of workers.
+func Run(worldMap *worldmap.WorldMap, opts Options) (*Report, error) {
+	if opts.Runs < 1 {
+		return nil, baterror.Wrap(baterror.ErrInvalidRunCount, fmt.Sprintf("(%v)", opts.Runs))
+	}
+	if err := opts.Config.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := opts.Config.MoveStrategies(worldMap.GetDirectionSystem()); err != nil {
+		return nil, err
+	}
+	workers := opts.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([]Result, opts.Runs)
+	errs := make([]error, opts.Runs)
+	runs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for run := range runs {
+				results[run], errs[run] = RunOne(worldMap.Clone(), opts.Seed+int64(run), opts)
+			}
+		}()
+	}
+	for run := 0; run < opts.Runs; run++ {
+		runs <- run
+	}
+	close(runs)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewReport(worldMap.GetCities(), results), nil
+}
+
+// Percentiles summarises a distribution
+type Percentiles struct {
+	Min  int     `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  int     `json:"p50"`
+	P90  int     `json:"p90"`
+	P99  int     `json:"p99"`
+	Max  int     `json:"max"`
+}
+
+// newPercentiles returns the Percentiles of values using the nearest-rank method
+func newPercentiles(values []int) (p Percentiles) {
+	if len(values) == 0 {
+		return
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+
+	rank := func(percent int) int {
+		i := (percent*len(sorted)+99)/100 - 1
+		if i < 0 {
+			i = 0
+		}
+		return sorted[i]
+	}
+
+	sum := 0
+	for _, v := range sorted {
+		sum += v
+	}
+	return Percentiles{
+		Min:  sorted[0],
+		Mean: float64(sum) / float64(len(sorted)),
+		P50:  rank(50),
+		P90:  rank(90),
+		P99:  rank(99),
+		Max:  sorted[len(sorted)-1],
+	}
+}
+
+// CityDestruction is how often a city was destroyed
+type CityDestruction struct {
+	City        worldmap.City `json:"city"`
+	Probability float64       `json:"probability"`
+}
+
+// Report holds the statistics of a batch of invasions
+type Report struct {
+	Runs        int                     `json:"runs"`
+	Conclusions map[invasion.Reason]int `json:"conclusions"`
+	Moves       Percentiles             `json:"moves"`
+	Survivors   Percentiles             `json:"survivors"`
+	Cities      []CityDestruction       `json:"cities"`
+}
+
+// NewReport aggregates results, listing cities in the given order
+func NewReport(cities []worldmap.City, results []Result) *Report {
+	report := &Report{
+		Runs:        len(results),
+		Conclusions: make(map[invasion.Reason]int),
+		Cities:      make([]CityDestruction, 0, len(cities)),
+	}
+
+	moves := make([]int, 0, len(results))
+	survivors := make([]int, 0, len(results))
+	destroyed := make(map[worldmap.City]int)
+	for _, result := range results {
+		report.Conclusions[result.Conclusion.Reason]++
+		moves = append(moves, result.Conclusion.Moves)
+		survivors = append(survivors, result.Survivors)
+		for _, city := range result.Destroyed {
+			destroyed[city]++
+		}
+	}
+	report.Moves = newPercentiles(moves)
+	report.Survivors = newPercentiles(survivors)
+
+	for _, city := range cities {
+		probability := 0.0
+		if len(results) > 0 {
+			probability = float64(destroyed[city]) / float64(len(results))
+		}
+		report.Cities = append(report.Cities, CityDestruction{City: city, Probability: probability})
+	}
+	return report
+}
@@ -0,0 +1,70 @@
+package batch_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/harry-hov/alien-invasion/batch"
+	"github.com/harry-hov/alien-invasion/invasion"
+	"github.com/harry-hov/alien-invasion/worldmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const worldMapInput string = `Foo north=Bar west=Baz south=Qu-ux
+Bar south=Foo west=Bee
+`
+
+func TestRun(t *testing.T) {
+	config := invasion.DefaultConfig()
+	config.MaxMoves = 100
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	require.Nil(t, err)
+	opts := batch.Options{Aliens: 6, Runs: 200, Seed: 1, Workers: 1, Config: config}
+	serial, err := batch.Run(worldMap, opts)
+	require.Nil(t, err)
+
+	opts.Workers = 8
+	parallel, err := batch.Run(worldMap, opts)
+	require.Nil(t, err)
+	assert.Equal(t, serial, parallel)
+
+	assert.Equal(t, 200, serial.Runs)
+	total := 0
+	for _, count := range serial.Conclusions {
+		total += count
+	}
+	assert.Equal(t, 200, total)
+	require.Equal(t, 5, len(serial.Cities))
+	assert.Equal(t, worldmap.City("Foo"), serial.Cities[0].City)
+	assert.LessOrEqual(t, serial.Moves.Min, serial.Moves.P50)
+	assert.LessOrEqual(t, serial.Moves.P50, serial.Moves.Max)
+
+	// The original WorldMap is left untouched
+	assert.Equal(t, 5, len(worldMap.GetCities()))
+	assert.Nil(t, worldMap.GetAlienList())
+}
+
+func TestRunInvalid(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	require.Nil(t, err)
+	_, err = batch.Run(worldMap, batch.Options{Aliens: 6, Runs: 0, Config: invasion.DefaultConfig()})
+	assert.NotNil(t, err)
+	_, err = batch.Run(worldMap, batch.Options{Aliens: 6, Runs: 1})
+	assert.NotNil(t, err)
+}
+
+func TestNewReport(t *testing.T) {
+	results := []batch.Result{
+		{Conclusion: invasion.Conclusion{Reason: invasion.AllAliensDead, Moves: 1}, Destroyed: []worldmap.City{"Foo"}, Survivors: 0},
+		{Conclusion: invasion.Conclusion{Reason: invasion.SingleSurvivor, Moves: 3}, Destroyed: []worldmap.City{"Foo", "Bar"}, Survivors: 1},
+		{Conclusion: invasion.Conclusion{Reason: invasion.SingleSurvivor, Moves: 2}, Survivors: 1},
+		{Conclusion: invasion.Conclusion{Reason: invasion.MaxMoves, Moves: 10}, Survivors: 2},
+	}
+	report := batch.NewReport([]worldmap.City{"Foo", "Bar", "Baz"}, results)
+
+	assert.Equal(t, map[invasion.Reason]int{invasion.AllAliensDead: 1, invasion.SingleSurvivor: 2, invasion.MaxMoves: 1}, report.Conclusions)
+	assert.Equal(t, batch.Percentiles{Min: 1, Mean: 4, P50: 2, P90: 10, P99: 10, Max: 10}, report.Moves)
+	assert.Equal(t, batch.Percentiles{Min: 0, Mean: 1, P50: 1, P90: 2, P99: 2, Max: 2}, report.Survivors)
+	assert.Equal(t, []batch.CityDestruction{{"Foo", 0.5}, {"Bar", 0.25}, {"Baz", 0}}, report.Cities)
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+
+	"github.com/harry-hov/alien-invasion/batch"
+	cmderror "github.com/harry-hov/alien-invasion/error"
+	"github.com/harry-hov/alien-invasion/invasion"
+	"github.com/spf13/cobra"
+)
+
+func CmdBatch() *cobra.Command {
+	var format string
+	var output string
+	var directions string
+	opts := batch.Options{Config: invasion.DefaultConfig()}
+	cmd := &cobra.Command{
+		Use:   "batch [world-file]",
+		Short: "Invade a World many times and report statistics",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filename := args[0]
+			worldFormat, err := getWorldFormat(filename, format)
+			if err != nil {
+				return err
+			}
+			if output != outputText && output != outputJSON {
+				return cmderror.Wrap(cmderror.ErrInvalidFormat, fmt.Sprintf("invalid value (%v) for [-o | --output] flag", output))
+			}
+
+			worldMap, _, err := readWorldFile(filename, worldFormat, directions, opts.Aliens)
+			if err != nil {
+				return err
+			}
+
+			if !cmd.Flags().Changed("seed") {
+				opts.Seed = time.Now().UnixNano()
+			}
+
+			report, err := batch.Run(worldMap, opts)
+			if err != nil {
+				return err
+			}
+
+			if output == outputJSON {
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(report)
+			}
+			return writeReport(cmd.OutOrStdout(), report)
+		},
+	}
+
+	cmd.Flags().UintVarP(&opts.Aliens, "aliens", "a", 0, "Alien Count")
+	cmd.Flags().IntVarP(&opts.Runs, "runs", "r", 100, "Number of Invasions")
+	cmd.Flags().Int64VarP(&opts.Seed, "seed", "s", 0, "Random Seed of the first Invasion (default: current time)")
+	cmd.Flags().IntVarP(&opts.Workers, "workers", "w", runtime.NumCPU(), "Parallel Invasions")
+	cmd.Flags().StringVarP(&format, "format", "f", "", "World File Format: text|json|yaml (default: from file extension)")
+	cmd.Flags().StringVarP(&output, "output", "o", outputText, "Output Format: text|json")
+	cmd.Flags().StringVar(&directions, "directions", "", "Direction System: compass|diagonal|vertical|hex (default: compass)")
+	cmd.Flags().IntVar(&opts.Config.MaxMoves, "max-moves", opts.Config.MaxMoves, "Maximum Moves")
+	cmd.Flags().IntVar(&opts.Config.FightThreshold, "fight-threshold", opts.Config.FightThreshold, "Minimum Aliens in a City to Fight")
+	cmd.Flags().BoolVar(&opts.Config.StopAtSingleSurvivor, "stop-at-survivor", opts.Config.StopAtSingleSurvivor, "Stop when a Single Alien Survives")
+	cmd.Flags().IntVar(&opts.Config.Shards, "shards", 0, "Draw Alien Moves Concurrently in N Shards (default: serially)")
+	cmd.Flags().StringArrayVar(&opts.Config.Strategies, "strategy", nil, "Alien Move Strategy, repeat to give Aliens Strategies in turn: random|stay-put:P|lazy|avoid-occupied|seek-nearest[:RADIUS]|follow-wall|biased:DIR=W,... (default: random)")
+
+	return cmd
+}
+
+// writeReport writes the batch report in human readable format
+func writeReport(w io.Writer, report *batch.Report) error {
+	out := fmt.Sprintf("Runs: %v\n", report.Runs)
+
+	out += "\nConclusions:\n"
+	for _, reason := range []invasion.Reason{
+		invasion.SingleSurvivor,
+		invasion.AllAliensDead,
+		invasion.AllTrapped,
+		invasion.AllCitiesDestroyed,
+		invasion.MaxMoves,
+	} {
+		count := report.Conclusions[reason]
+		out += fmt.Sprintf("  %-22v %6v (%5.1f%%)\n", reason, count, 100*float64(count)/float64(report.Runs))
+	}
+
+	out += "\n"
+	out += fmt.Sprintf("Moves:     %v\n", formatPercentiles(report.Moves))
+	out += fmt.Sprintf("Survivors: %v\n", formatPercentiles(report.Survivors))
+
+	out += "\nCity Destruction:\n"
+	for _, city := range report.Cities {
+		out += fmt.Sprintf("  %-22v %5.1f%%\n", city.City, 100*city.Probability)
+	}
+
+	_, err := io.WriteString(w, out)
+	return err
+}
+
+func formatPercentiles(p batch.Percentiles) string {
+	return fmt.Sprintf("min=%v mean=%.2f p50=%v p90=%v p99=%v max=%v", p.Min, p.Mean, p.P50, p.P90, p.P99, p.Max)
+}
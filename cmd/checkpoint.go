@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/harry-hov/alien-invasion/invasion"
+)
+
+// checkpointer saves a snapshot of an invasion every N moves
+type checkpointer struct {
+	every int
+	path  string
+}
+
+// save writes the snapshot next to the checkpoint file
+// and renames it, so a crash never leaves a partial checkpoint
+func (c checkpointer) save(in *invasion.Invasion) error {
+	snapshot, err := in.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	fp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fp.Name())
+
+	if err := invasion.WriteSnapshot(fp, snapshot); err != nil {
+		fp.Close()
+		return err
+	}
+	if err := fp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(fp.Name(), c.path)
+}
+
+// play runs the invasion until it is finished, saving checkpoints
+// Results are printed by the reporter
+func play(in *invasion.Invasion, report reporter, c checkpointer) error {
+	for !in.IsFinished() && report.Err() == nil {
+		in.MakeMove()
+		in.Fight()
+
+		if c.every > 0 && in.GetCurrentMove()%c.every == 0 && !in.IsFinished() {
+			if err := c.save(in); err != nil {
+				return err
+			}
+		}
+	}
+	return report.Err()
+}
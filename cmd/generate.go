@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"time"
+
+	cmderror "github.com/harry-hov/alien-invasion/error"
+	"github.com/harry-hov/alien-invasion/generate"
+	"github.com/harry-hov/alien-invasion/worldmap"
+	"github.com/spf13/cobra"
+)
+
+func CmdGenerate() *cobra.Command {
+	var shape string
+	var format string
+	var directions string
+	opts := generate.Options{Coordinates: true}
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate a World file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			worldFormat := worldmap.Format(format)
+			if !worldFormat.IsValid() {
+				return cmderror.Wrap(cmderror.ErrInvalidFormat, fmt.Sprintf("invalid value (%v) for [-f | --format] flag", format))
+			}
+			opts.Shape = generate.Shape(shape)
+			if !opts.Shape.IsValid() {
+				return cmderror.Wrap(cmderror.ErrInvalidShape, fmt.Sprintf("invalid value (%v) for [-t | --shape] flag", shape))
+			}
+			ds, err := getDirectionSystem(directions)
+			if err != nil {
+				return err
+			}
+			opts.Directions = ds
+			if !cmd.Flags().Changed("seed") {
+				opts.Seed = time.Now().UnixNano()
+			}
+
+			worldMap, err := generate.Generate(opts)
+			if err != nil {
+				return err
+			}
+
+			w := bufio.NewWriter(cmd.OutOrStdout())
+			if err := worldMap.Encode(w, worldFormat); err != nil {
+				return err
+			}
+			return w.Flush()
+		},
+	}
+
+	shapes := make([]string, len(generate.Shapes))
+	for i, s := range generate.Shapes {
+		shapes[i] = string(s)
+	}
+	cmd.Flags().StringVarP(&shape, "shape", "t", string(generate.ShapeGrid), fmt.Sprintf("World Shape: %v", strings.Join(shapes, "|")))
+	cmd.Flags().IntVarP(&opts.Cities, "cities", "n", 100, "City Count")
+	cmd.Flags().Int64VarP(&opts.Seed, "seed", "s", 0, "Random Seed (default: current time)")
+	cmd.Flags().StringVarP(&format, "format", "f", string(worldmap.FormatText), "World File Format: text|json|yaml")
+	cmd.Flags().StringVar(&directions, "directions", "", "Direction System: compass|diagonal|vertical|hex (default: compass)")
+	cmd.Flags().BoolVar(&opts.Coordinates, "coordinates", opts.Coordinates, "Write the Coordinates of Cities")
+
+	return cmd
+}
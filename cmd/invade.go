@@ -1,63 +1,121 @@
 package cmd
 
 import (
-	"fmt"
+	"bufio"
 	"os"
+	"time"
 
-	cmderror "github.com/harry-hov/alien-invasion/error"
 	"github.com/harry-hov/alien-invasion/invasion"
-	"github.com/harry-hov/alien-invasion/worldmap"
+	"github.com/harry-hov/alien-invasion/replay"
+	"github.com/harry-hov/alien-invasion/rng"
 	"github.com/spf13/cobra"
 )
 
 func CmdInvade() *cobra.Command {
 	var alienCount uint
+	var seed int64
+	var format string
+	var output string
+	var record string
+	var directions string
+	var checkpoint checkpointer
+	config := invasion.DefaultConfig()
 	cmd := &cobra.Command{
 		Use:   "invade [world-file]",
 		Short: "Invade a World",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			filename := args[0]
-			if filename == "" {
-				return cmderror.Wrap(cmderror.ErrInvalidFileName, "")
+			worldFormat, err := getWorldFormat(filename, format)
+			if err != nil {
+				return err
 			}
-			if alienCount == 0 {
-				return cmderror.Wrap(cmderror.ErrInvalidAlienCount, "invalid value (0) for [-a | --aliens] flag")
+
+			if err := config.Validate(); err != nil {
+				return err
 			}
 
-			fp, err := os.Open(filename)
+			report, err := newReporter(cmd.OutOrStdout(), output, worldFormat)
 			if err != nil {
 				return err
 			}
 
-			worldMap, err := worldmap.InitWorldMap(fp)
+			worldMap, worldHash, err := readWorldFile(filename, worldFormat, directions, alienCount)
 			if err != nil {
 				return err
 			}
+			if _, err := config.MoveStrategies(worldMap.GetDirectionSystem()); err != nil {
+				return err
+			}
 
-			// Check for empty WorldMap
-			if worldMap.GetCities() == nil {
-				return cmderror.Wrap(cmderror.ErrInvalidCity, "No cities to invade")
+			if !cmd.Flags().Changed("seed") {
+				seed = time.Now().UnixNano()
 			}
 
-			invasion := invasion.InitInvasion(worldMap, alienCount)
+			var sink invasion.EventSink = report
+			var recorder *replay.Recorder
+			var recordWriter *bufio.Writer
+			if record != "" {
+				if err := replay.CheckRecordable(worldMap); err != nil {
+					return err
+				}
+				fp, err := os.Create(record)
+				if err != nil {
+					return err
+				}
+				defer fp.Close()
 
-			// Invasion begins
-			for !invasion.IsFinished() {
-				invasion.MakeMove()
-				invasion.Fight()
+				recordWriter = bufio.NewWriter(fp)
+				recorder, err = replay.NewRecorder(recordWriter, replay.Header{
+					Seed:        seed,
+					Config:      config,
+					Aliens:      alienCount,
+					World:       filename,
+					WorldFormat: worldFormat,
+					Directions:  worldMap.GetDirectionSystem().Name(),
+					WorldHash:   worldHash,
+				})
+				if err != nil {
+					return err
+				}
+				sink = invasion.MultiSink(recorder, report)
 			}
 
-			// Print Results
-			fmt.Println("Conclusion:", invasion.Conclusion())
-			fmt.Println("\nRemaining World:")
-			invasion.GetWorldMap().Print()
+			invasion, err := invasion.InitInvasion(worldMap, alienCount, config, rng.NewSource(seed), sink)
+			if err != nil {
+				return err
+			}
+
+			// Invasion begins, results are printed by the reporter
+			if err := play(invasion, report, checkpoint); err != nil {
+				return err
+			}
 
+			if recorder != nil {
+				if err := recorder.Err(); err != nil {
+					return err
+				}
+				if err := recordWriter.Flush(); err != nil {
+					return err
+				}
+			}
 			return nil
 		},
 	}
 
 	cmd.Flags().UintVarP(&alienCount, "aliens", "a", 0, "Alien Count")
+	cmd.Flags().Int64VarP(&seed, "seed", "s", 0, "Random Seed (default: current time)")
+	cmd.Flags().StringVarP(&format, "format", "f", "", "World File Format: text|json|yaml (default: from file extension)")
+	cmd.Flags().StringVarP(&output, "output", "o", outputText, "Output Format: text|json|ndjson")
+	cmd.Flags().StringVar(&directions, "directions", "", "Direction System: compass|diagonal|vertical|hex (default: compass)")
+	cmd.Flags().StringVar(&record, "record", "", "Write a Replay Log of the Invasion to the file")
+	cmd.Flags().IntVar(&checkpoint.every, "checkpoint-every", 0, "Save a Snapshot of the Invasion every N Moves")
+	cmd.Flags().StringVar(&checkpoint.path, "checkpoint", "snapshot.json", "Snapshot File")
+	cmd.Flags().IntVar(&config.MaxMoves, "max-moves", config.MaxMoves, "Maximum Moves")
+	cmd.Flags().IntVar(&config.FightThreshold, "fight-threshold", config.FightThreshold, "Minimum Aliens in a City to Fight")
+	cmd.Flags().BoolVar(&config.StopAtSingleSurvivor, "stop-at-survivor", config.StopAtSingleSurvivor, "Stop when a Single Alien Survives")
+	cmd.Flags().IntVar(&config.Shards, "shards", 0, "Draw Alien Moves Concurrently in N Shards (default: serially)")
+	cmd.Flags().StringArrayVar(&config.Strategies, "strategy", nil, "Alien Move Strategy, repeat to give Aliens Strategies in turn: random|stay-put:P|lazy|avoid-occupied|seek-nearest[:RADIUS]|follow-wall|biased:DIR=W,... (default: random)")
 
 	return cmd
 }
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	cmderror "github.com/harry-hov/alien-invasion/error"
+	"github.com/harry-hov/alien-invasion/invasion"
+	"github.com/harry-hov/alien-invasion/worldmap"
+)
+
+const (
+	outputText   = "text"
+	outputJSON   = "json"
+	outputNDJSON = "ndjson"
+)
+
+// reporter is an invasion.EventSink writing the events of an invasion
+// Err returns the first error encountered while writing.
+type reporter interface {
+	invasion.EventSink
+	Err() error
+}
+
+// newReporter returns the reporter for the output mode
+// The remaining world of text output is written in worldFormat
+func newReporter(w io.Writer, output string, worldFormat worldmap.Format) (reporter, error) {
+	switch output {
+	case outputText:
+		return &textReporter{w: w, worldFormat: worldFormat}, nil
+	case outputJSON:
+		return &jsonReporter{w: w, events: make([]record, 0)}, nil
+	case outputNDJSON:
+		return &ndjsonReporter{encoder: json.NewEncoder(w)}, nil
+	}
+	return nil, cmderror.Wrap(cmderror.ErrInvalidFormat, fmt.Sprintf("invalid value (%v) for [-o | --output] flag", output))
+}
+
+// record is a single structured event of an invasion
+type record struct {
+	Type string `json:"type"`
+	*invasion.Destruction
+	*summary
+}
+
+// summary is the final record of an invasion
+type summary struct {
+	Conclusion invasion.Conclusion `json:"conclusion"`
+	Moves      int                 `json:"moves"`
+	Survivors  []worldmap.Alien    `json:"survivors"`
+	World      *worldmap.WorldMap  `json:"world"`
+}
+
+func destroyedRecord(d invasion.Destruction) record {
+	return record{Type: "destroyed", Destruction: &d}
+}
+
+func summaryRecord(i *invasion.Invasion) record {
+	survivors := i.GetWorldMap().GetAlienList()
+	if survivors == nil {
+		survivors = make([]worldmap.Alien, 0)
+	}
+	return record{Type: "summary", summary: &summary{
+		Conclusion: i.Conclusion(),
+		Moves:      i.GetCurrentMove(),
+		Survivors:  survivors,
+		World:      i.GetWorldMap(),
+	}}
+}
+
+// errReporter keeps the first write error of a reporter
+type errReporter struct {
+	invasion.NopSink
+	err error
+}
+
+func (r *errReporter) setErr(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *errReporter) Err() error {
+	return r.err
+}
+
+// textReporter writes human readable output
+type textReporter struct {
+	errReporter
+	w           io.Writer
+	worldFormat worldmap.Format
+}
+
+func (r *textReporter) CityDestroyed(d invasion.Destruction) {
+	_, err := fmt.Fprintln(r.w, d)
+	r.setErr(err)
+}
+
+func (r *textReporter) InvasionFinished(i *invasion.Invasion) {
+	_, err := fmt.Fprintln(r.w, "Conclusion:", i.Conclusion())
+	r.setErr(err)
+	_, err = fmt.Fprintln(r.w, "\nRemaining World:")
+	r.setErr(err)
+	r.setErr(i.GetWorldMap().Encode(r.w, r.worldFormat))
+}
+
+// jsonReporter writes a single JSON document once the invasion is finished
+type jsonReporter struct {
+	errReporter
+	w      io.Writer
+	events []record
+}
+
+func (r *jsonReporter) CityDestroyed(d invasion.Destruction) {
+	r.events = append(r.events, destroyedRecord(d))
+}
+
+func (r *jsonReporter) InvasionFinished(i *invasion.Invasion) {
+	encoder := json.NewEncoder(r.w)
+	encoder.SetIndent("", "  ")
+	r.setErr(encoder.Encode(struct {
+		Events  []record `json:"events"`
+		Summary record   `json:"summary"`
+	}{r.events, summaryRecord(i)}))
+}
+
+// ndjsonReporter writes one JSON record per line as events happen
+type ndjsonReporter struct {
+	errReporter
+	encoder *json.Encoder
+}
+
+func (r *ndjsonReporter) CityDestroyed(d invasion.Destruction) {
+	r.setErr(r.encoder.Encode(destroyedRecord(d)))
+}
+
+func (r *ndjsonReporter) InvasionFinished(i *invasion.Invasion) {
+	r.setErr(r.encoder.Encode(summaryRecord(i)))
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	cmderror "github.com/harry-hov/alien-invasion/error"
+	"github.com/harry-hov/alien-invasion/replay"
+	"github.com/spf13/cobra"
+)
+
+func CmdReplay() *cobra.Command {
+	var world string
+	var untilMove int
+	cmd := &cobra.Command{
+		Use:   "replay [log-file]",
+		Short: "Replay a recorded Invasion",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fp, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer fp.Close()
+
+			log, err := replay.ReadLog(fp)
+			if err != nil {
+				return err
+			}
+
+			filename := log.Header.World
+			if world != "" {
+				filename = world
+			}
+			worldMap, worldHash, err := readWorldFile(filename, log.Header.WorldFormat, log.Header.Directions, log.Header.Aliens)
+			if err != nil {
+				return err
+			}
+			if worldHash != log.Header.WorldHash {
+				return cmderror.Wrap(cmderror.ErrInvalidLog, fmt.Sprintf("world file (%v) differs from the recorded one", filename))
+			}
+
+			report := &textReporter{w: cmd.OutOrStdout(), worldFormat: log.Header.WorldFormat}
+			invasion, err := log.Replay(worldMap, untilMove, report)
+			if err != nil {
+				return err
+			}
+			if err := report.Err(); err != nil {
+				return err
+			}
+
+			// Dump state if stopped before the end
+			if !invasion.IsFinished() {
+				out := fmt.Sprintf("State at move %v:\n", invasion.GetCurrentMove())
+				for _, alien := range invasion.GetWorldMap().GetAlienList() {
+					city, _ := invasion.GetWorldMap().GetAlienCity(alien)
+					if moves := invasion.GetWorldMap().GetAlienTransit(alien); moves > 0 {
+						out += fmt.Sprintf("%v travelling to %v (%v moves left)\n", alien, city, moves)
+					} else {
+						out += fmt.Sprintf("%v in %v\n", alien, city)
+					}
+				}
+				out += "\nRemaining World:\n"
+				if _, err := fmt.Fprint(cmd.OutOrStdout(), out); err != nil {
+					return err
+				}
+				return invasion.GetWorldMap().Encode(cmd.OutOrStdout(), log.Header.WorldFormat)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&world, "world", "", "World File (default: the recorded world file)")
+	cmd.Flags().IntVar(&untilMove, "until-move", -1, "Stop after the move and dump the state, -1 replays the whole log")
+
+	return cmd
+}
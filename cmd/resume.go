@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/harry-hov/alien-invasion/invasion"
+	"github.com/harry-hov/alien-invasion/worldmap"
+	"github.com/spf13/cobra"
+)
+
+func CmdResume() *cobra.Command {
+	var output string
+	var checkpoint checkpointer
+	cmd := &cobra.Command{
+		Use:   "resume [snapshot-file]",
+		Short: "Resume an Invasion from a Snapshot",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filename := args[0]
+			report, err := newReporter(cmd.OutOrStdout(), output, worldmap.FormatText)
+			if err != nil {
+				return err
+			}
+
+			fp, err := os.Open(filename)
+			if err != nil {
+				return err
+			}
+			defer fp.Close()
+
+			snapshot, err := invasion.ReadSnapshot(fp)
+			if err != nil {
+				return err
+			}
+
+			invasion, err := invasion.Resume(snapshot, report)
+			if err != nil {
+				return err
+			}
+
+			if checkpoint.path == "" {
+				checkpoint.path = filename
+			}
+			return play(invasion, report, checkpoint)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", outputText, "Output Format: text|json|ndjson")
+	cmd.Flags().IntVar(&checkpoint.every, "checkpoint-every", 0, "Save a Snapshot of the Invasion every N Moves")
+	cmd.Flags().StringVar(&checkpoint.path, "checkpoint", "", "Snapshot File (default: the resumed snapshot file)")
+
+	return cmd
+}
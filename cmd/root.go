@@ -16,6 +16,11 @@ func GetAlienInvasionCmd() *cobra.Command {
 
 	cmd.CompletionOptions.DisableDefaultCmd = true
 	cmd.AddCommand(CmdInvade())
+	cmd.AddCommand(CmdBatch())
+	cmd.AddCommand(CmdReplay())
+	cmd.AddCommand(CmdResume())
+	cmd.AddCommand(CmdValidate())
+	cmd.AddCommand(CmdGenerate())
 
 	return cmd
 }
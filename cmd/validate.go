@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	cmderror "github.com/harry-hov/alien-invasion/error"
+	"github.com/harry-hov/alien-invasion/worldmap"
+	"github.com/spf13/cobra"
+)
+
+func CmdValidate() *cobra.Command {
+	var format string
+	var strict bool
+	var directions string
+	var geography bool
+	cmd := &cobra.Command{
+		Use:   "validate [world-file]",
+		Short: "Report every problem of a World file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filename := args[0]
+			worldFormat, err := getWorldFormat(filename, format)
+			if err != nil {
+				return err
+			}
+
+			ds, err := getDirectionSystem(directions)
+			if err != nil {
+				return err
+			}
+
+			fp, err := os.Open(filename)
+			if err != nil {
+				return err
+			}
+			defer fp.Close()
+
+			var worldMap *worldmap.WorldMap
+			if worldFormat == worldmap.FormatText {
+				worldMap, err = worldmap.Parse(fp, worldmap.ParseOptions{CollectErrors: true, AllowIsolatedCities: !strict, Directions: ds})
+			} else {
+				worldMap, err = worldmap.DecodeWithDirections(fp, worldFormat, ds)
+			}
+			if err == nil && geography {
+				err = worldMap.CheckGeography()
+			}
+			if err == nil {
+				_, err := fmt.Fprintf(cmd.OutOrStdout(), "%v: valid\n", filename)
+				return err
+			}
+
+			// Report problems as file:line:column: description
+			var problems cmderror.ErrorList
+			if !errors.As(err, &problems) {
+				problems = cmderror.ErrorList{err}
+			}
+			out := ""
+			for _, problem := range problems {
+				var parseErr *cmderror.ParseError
+				if errors.As(problem, &parseErr) {
+					out += fmt.Sprintf("%v:%v:%v: %v\n", filename, parseErr.Line, parseErr.Column, parseErr.Err)
+				} else {
+					out += fmt.Sprintf("%v: %v\n", filename, problem)
+				}
+			}
+			if _, err := fmt.Fprint(cmd.OutOrStdout(), out); err != nil {
+				return err
+			}
+
+			cmd.SilenceUsage = true
+			return cmderror.Wrap(cmderror.ErrInvalidWorld, fmt.Sprintf("%v problem(s) found", len(problems)))
+		},
+	}
+
+	cmd.Flags().StringVarP(&format, "format", "f", "", "World File Format: text|json|yaml (default: from file extension)")
+	cmd.Flags().StringVar(&directions, "directions", "", "Direction System: compass|diagonal|vertical|hex (default: compass)")
+	cmd.Flags().BoolVar(&geography, "geography", false, "Check that Directions and Coordinates of Cities are consistent")
+	cmd.Flags().BoolVar(&strict, "strict", false, "Reject isolated cities in text World files")
+
+	return cmd
+}
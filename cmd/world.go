@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	cmderror "github.com/harry-hov/alien-invasion/error"
+	"github.com/harry-hov/alien-invasion/worldmap"
+)
+
+// getWorldFormat returns the format of the world file
+// from the [-f | --format] flag or else from the file extension
+func getWorldFormat(filename, format string) (worldmap.Format, error) {
+	if filename == "" {
+		return "", cmderror.Wrap(cmderror.ErrInvalidFileName, "")
+	}
+	worldFormat := worldmap.FormatFromFilename(filename)
+	if format != "" {
+		worldFormat = worldmap.Format(format)
+	}
+	if !worldFormat.IsValid() {
+		return "", cmderror.Wrap(cmderror.ErrInvalidFormat, fmt.Sprintf("invalid value (%v) for [-f | --format] flag", format))
+	}
+	return worldFormat, nil
+}
+
+// getDirectionSystem returns the direction system named by the
+// [--directions] flag, nil if empty so the world file decides
+func getDirectionSystem(name string) (*worldmap.DirectionSystem, error) {
+	if name == "" {
+		return nil, nil
+	}
+	return worldmap.LookupDirectionSystem(name)
+}
+
+// decodeWorldMap returns the WorldMap to invade
+// It fails if the WorldMap has no cities or, when alienCount is 0, no aliens
+func decodeWorldMap(reader io.Reader, format worldmap.Format, directions string, alienCount uint) (*worldmap.WorldMap, error) {
+	ds, err := getDirectionSystem(directions)
+	if err != nil {
+		return nil, err
+	}
+	worldMap, err := worldmap.DecodeWithDirections(reader, format, ds)
+	if err != nil {
+		return nil, err
+	}
+
+	// Aliens can also be placed by the world file
+	if alienCount == 0 && worldMap.AlienCount() == 0 {
+		return nil, cmderror.Wrap(cmderror.ErrInvalidAlienCount, "invalid value (0) for [-a | --aliens] flag")
+	}
+
+	// Check for empty WorldMap
+	if worldMap.CityCount() == 0 {
+		return nil, cmderror.Wrap(cmderror.ErrInvalidCity, "No cities to invade")
+	}
+	return worldMap, nil
+}
+
+// readWorldFile returns the WorldMap to invade from the world file
+// along with the SHA-256 of the file content
+func readWorldFile(filename string, format worldmap.Format, directions string, alienCount uint) (*worldmap.WorldMap, string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, "", err
+	}
+	worldMap, err := decodeWorldMap(bytes.NewReader(data), format, directions, alienCount)
+	if err != nil {
+		return nil, "", err
+	}
+	hash := sha256.Sum256(data)
+	return worldMap, hex.EncodeToString(hash[:]), nil
+}
@@ -2,16 +2,90 @@ package error
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 )
 
 var (
+	ErrInvalidAlien      = errors.New("invalid alien")
 	ErrInvalidAlienCount = errors.New("invalid alien count")
 	ErrInvalidCity       = errors.New("invalid city")
+	ErrInvalidCityCount  = errors.New("invalid city count")
+	ErrInvalidConfig     = errors.New("invalid config")
 	ErrInvalidDirection  = errors.New("invalid direction")
 	ErrInvalidFileName   = errors.New("invalid filename")
+	ErrInvalidFormat     = errors.New("invalid format")
+	ErrInvalidGeography  = errors.New("invalid geography")
+	ErrInvalidLog        = errors.New("invalid log")
+	ErrInvalidRunCount   = errors.New("invalid run count")
+	ErrInvalidShape      = errors.New("invalid shape")
+	ErrInvalidSnapshot   = errors.New("invalid snapshot")
+	ErrInvalidStrategy   = errors.New("invalid strategy")
+	ErrInvalidWorld      = errors.New("invalid world")
 )
 
+// Wrap adds the description to err
+// The result still matches err with errors.Is
 func Wrap(err error, description string) error {
-	return errors.New(strings.Join([]string{err.Error(), description}, " : "))
+	return fmt.Errorf("%w : %s", err, description)
+}
+
+// MapError is a problem with a world map
+// Fields not related to the problem are left empty.
+type MapError struct {
+	// Err is the Err* value describing the kind of problem
+	Err         error
+	Description string
+
+	City      string
+	Direction string
+	// ConflictingCity is the city already linked in Direction of City
+	ConflictingCity string
+	Alien           string
+}
+
+func (e *MapError) Error() string {
+	return strings.Join([]string{e.Err.Error(), e.Description}, " : ")
+}
+
+func (e *MapError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError is a problem found at a position of a file
+type ParseError struct {
+	Line   int
+	Column int
+	// Token is the offending token
+	Token string
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %v, column %v: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorList is a list of errors reported together
+type ErrorList []error
+
+func (l ErrorList) Error() string {
+	out := make([]string, 0, len(l))
+	for _, err := range l {
+		out = append(out, err.Error())
+	}
+	return strings.Join(out, "\n")
+}
+
+// Is reports whether any error of the list matches target
+func (l ErrorList) Is(target error) bool {
+	for _, err := range l {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
 }
@@ -11,5 +11,46 @@ import (
 func TestWrap(t *testing.T) {
 	actual := e.Wrap(e.ErrInvalidCity, "invalid city")
 	expected := errors.New("invalid city : invalid city")
-	assert.Equal(t, expected, actual)
+	assert.Equal(t, expected.Error(), actual.Error())
+	assert.True(t, errors.Is(actual, e.ErrInvalidCity))
+	assert.False(t, errors.Is(actual, e.ErrInvalidDirection))
+}
+
+func TestMapError(t *testing.T) {
+	var err error = &e.MapError{
+		Err:             e.ErrInvalidDirection,
+		Description:     "ambiguous direction (north) from city (Foo) to (Baz), already leads to (Bar)",
+		City:            "Foo",
+		Direction:       "north",
+		ConflictingCity: "Bar",
+	}
+	assert.Equal(t, "invalid direction : ambiguous direction (north) from city (Foo) to (Baz), already leads to (Bar)", err.Error())
+	assert.True(t, errors.Is(err, e.ErrInvalidDirection))
+
+	var mapErr *e.MapError
+	assert.True(t, errors.As(e.Wrap(err, "wrapped"), &mapErr))
+	assert.Equal(t, "Bar", mapErr.ConflictingCity)
+}
+
+func TestParseError(t *testing.T) {
+	err := &e.ParseError{
+		Line:   3,
+		Column: 5,
+		Token:  "northBar",
+		Err:    e.Wrap(e.ErrInvalidDirection, "cannot parse direction entry (northBar)"),
+	}
+	assert.Equal(t, "line 3, column 5: invalid direction : cannot parse direction entry (northBar)", err.Error())
+	assert.True(t, errors.Is(err, e.ErrInvalidDirection))
+	assert.False(t, errors.Is(err, e.ErrInvalidCity))
+}
+
+func TestErrorList(t *testing.T) {
+	list := e.ErrorList{
+		&e.ParseError{Line: 1, Column: 1, Err: e.Wrap(e.ErrInvalidCity, "isolated city (Foo)")},
+		e.ErrInvalidAlien,
+	}
+	assert.Equal(t, "line 1, column 1: invalid city : isolated city (Foo)\ninvalid alien", list.Error())
+	assert.True(t, errors.Is(list, e.ErrInvalidCity))
+	assert.True(t, errors.Is(list, e.ErrInvalidAlien))
+	assert.False(t, errors.Is(list, e.ErrInvalidDirection))
 }
@@ -0,0 +1,425 @@
+package generate
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	generror "github.com/harry-hov/alien-invasion/error"
+	"github.com/harry-hov/alien-invasion/worldmap"
+)
+
+// Shape is the layout of a generated world
+type Shape string
+
+const (
+	// ShapeGrid is a full grid of cities, filled row by row
+	ShapeGrid = Shape("grid")
+	// ShapeRandom is a random connected subset of the roads of a grid,
+	// with cities slightly off their grid position
+	ShapeRandom = Shape("random")
+	// ShapeTree is a random tree without cycles
+	ShapeTree = Shape("tree")
+	// ShapeRing is a single loop of cities around a rectangle
+	ShapeRing = Shape("ring")
+	// ShapeScaleFree links new cities to well connected ones
+	// (preferential attachment), so a few cities get most roads
+	ShapeScaleFree = Shape("scale-free")
+)
+
+// Shapes lists the valid shapes
+var Shapes = []Shape{ShapeGrid, ShapeRandom, ShapeTree, ShapeRing, ShapeScaleFree}
+
+// IsValid checks if shape is valid
+func (s Shape) IsValid() bool {
+	for _, shape := range Shapes {
+		if s == shape {
+			return true
+		}
+	}
+	return false
+}
+
+// Options holds the parameters of a generated world
+type Options struct {
+	Shape Shape
+	// Cities is the number of cities of the world
+	Cities int
+	// Seed of the random choices, the same options always
+	// generate the same world
+	Seed int64
+	// Directions is the direction system of the world (default: Compass)
+	// Grid, random and ring shapes only use north, east, south and west.
+	Directions *worldmap.DirectionSystem
+	// Coordinates places the cities on a map, except for scale-free worlds
+	Coordinates bool
+}
+
+// randomRoadProbability is the probability to keep a road of the grid
+// which is not needed to connect a random world
+const randomRoadProbability = 0.3
+
+// scaleFreeRoads is the number of roads linking a new city of a scale-free world
+const scaleFreeRoads = 2
+
+// Generate returns a connected world of the given shape
+// Cities are named city-0, city-1, ... in the order they are added.
+func Generate(opts Options) (*worldmap.WorldMap, error) {
+	if opts.Directions == nil {
+		opts.Directions = worldmap.Compass
+	}
+	if !opts.Shape.IsValid() {
+		return nil, generror.Wrap(generror.ErrInvalidShape, fmt.Sprintf("(%v)", opts.Shape))
+	}
+	if opts.Cities < 1 || (opts.Shape == ShapeRing && opts.Cities < 4) {
+		return nil, generror.Wrap(generror.ErrInvalidCityCount, fmt.Sprintf("(%v) for shape (%v)", opts.Cities, opts.Shape))
+	}
+
+	g := &generator{
+		opts:     opts,
+		worldMap: worldmap.NewWithDirections(opts.Directions),
+		rand:     rand.New(rand.NewSource(opts.Seed)),
+	}
+	var err error
+	switch opts.Shape {
+	case ShapeGrid:
+		err = g.grid(false)
+	case ShapeRandom:
+		err = g.grid(true)
+	case ShapeTree:
+		err = g.tree()
+	case ShapeRing:
+		err = g.ring()
+	case ShapeScaleFree:
+		err = g.scaleFree()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g.worldMap, nil
+}
+
+type generator struct {
+	opts     Options
+	worldMap *worldmap.WorldMap
+	rand     *rand.Rand
+}
+
+func city(i int) worldmap.City {
+	return worldmap.City(fmt.Sprintf("city-%v", i))
+}
+
+// addCities adds the cities in order, placed at the positions if any
+func (g *generator) addCities(positions []worldmap.Vector) {
+	for i := 0; i < g.opts.Cities; i++ {
+		g.worldMap.AddCity(city(i))
+		if g.opts.Coordinates && positions != nil {
+			if err := g.worldMap.SetCityCoordinates(city(i), positions[i]); err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// requireCompass checks that the direction system has the compass points
+func (g *generator) requireCompass() error {
+	for _, direction := range worldmap.Directions {
+		if !g.opts.Directions.IsValid(direction) {
+			return generror.Wrap(generror.ErrInvalidShape, fmt.Sprintf("shape (%v) needs direction (%v), not in direction system (%v)",
+				g.opts.Shape, direction, g.opts.Directions.Name()))
+		}
+	}
+	return nil
+}
+
+// road is a road of a grid between two cities
+type road struct {
+	from, to  int
+	direction worldmap.Direction
+	cost      int
+}
+
+// grid lays the cities row by row on a square grid. Random worlds keep
+// a random spanning tree of the grid plus some of the other roads,
+// and move cities slightly off their grid position.
+func (g *generator) grid(random bool) error {
+	if err := g.requireCompass(); err != nil {
+		return err
+	}
+	n := g.opts.Cities
+	width := int(math.Ceil(math.Sqrt(float64(n))))
+
+	positions := make([]worldmap.Vector, n)
+	var roads []road
+	for i := 0; i < n; i++ {
+		positions[i] = worldmap.Vector{X: float64(i % width), Y: float64(i / width)}
+		if random {
+			// Within 0.2 of the grid, roads keep matching their direction
+			positions[i].X += 0.4*g.rand.Float64() - 0.2
+			positions[i].Y += 0.4*g.rand.Float64() - 0.2
+		}
+		if i%width+1 < width && i+1 < n {
+			roads = append(roads, road{from: i, to: i + 1, direction: worldmap.East, cost: 1})
+		}
+		if i+width < n {
+			roads = append(roads, road{from: i, to: i + width, direction: worldmap.North, cost: 1})
+		}
+	}
+	g.addCities(positions)
+
+	if random {
+		g.rand.Shuffle(len(roads), func(i, j int) { roads[i], roads[j] = roads[j], roads[i] })
+		components := newUnionFind(n)
+		kept := roads[:0]
+		for _, r := range roads {
+			if components.union(r.from, r.to) || g.rand.Float64() < randomRoadProbability {
+				kept = append(kept, r)
+			}
+		}
+		roads = kept
+		sort.Slice(roads, func(i, j int) bool {
+			return roads[i].from < roads[j].from || (roads[i].from == roads[j].from && roads[i].to < roads[j].to)
+		})
+	}
+	return g.appendRoads(roads)
+}
+
+// ring lays the cities around a rectangle. With an odd number of cities
+// a road of cost 2 skips a corner-free spot, so the loop still closes.
+func (g *generator) ring() error {
+	if err := g.requireCompass(); err != nil {
+		return err
+	}
+	n := g.opts.Cities
+	spots := n + n%2
+	height := spots / 4
+	width := spots/2 - height
+
+	// Walk around the rectangle: east, north, west then south
+	var positions []worldmap.Vector
+	for x := 0; x < width; x++ {
+		if n%2 == 1 && x == 1 {
+			continue
+		}
+		positions = append(positions, worldmap.Vector{X: float64(x)})
+	}
+	for y := 0; y < height; y++ {
+		positions = append(positions, worldmap.Vector{X: float64(width), Y: float64(y)})
+	}
+	for x := width; x > 0; x-- {
+		positions = append(positions, worldmap.Vector{X: float64(x), Y: float64(height)})
+	}
+	for y := height; y > 0; y-- {
+		positions = append(positions, worldmap.Vector{Y: float64(y)})
+	}
+	g.addCities(positions)
+
+	roads := make([]road, 0, n)
+	for i := 0; i < n; i++ {
+		from, to := positions[i], positions[(i+1)%n]
+		r := road{from: i, to: (i + 1) % n}
+		switch {
+		case to.X > from.X:
+			r.direction, r.cost = worldmap.East, int(to.X-from.X)
+		case to.X < from.X:
+			r.direction, r.cost = worldmap.West, int(from.X-to.X)
+		case to.Y > from.Y:
+			r.direction, r.cost = worldmap.North, int(to.Y-from.Y)
+		default:
+			r.direction, r.cost = worldmap.South, int(from.Y-to.Y)
+		}
+		roads = append(roads, r)
+	}
+	return g.appendRoads(roads)
+}
+
+// tree grows the world from city-0, linking each new city to a random
+// city with a free direction. With a direction system placing directions
+// on a grid, two cities never share the same position.
+func (g *generator) tree() error {
+	n := g.opts.Cities
+	directions := g.opts.Directions.Directions()
+	_, hasOffsets := g.opts.Directions.Offset(directions[0])
+
+	positions := []worldmap.Vector{{}}
+	occupied := map[[3]int64]bool{spot(worldmap.Vector{}): true}
+	used := newSlots(n, g.opts.Directions)
+	// candidates are the cities which may still have a free direction
+	candidates := []int{0}
+	var roads []road
+	for i := 1; i < n; i++ {
+		for {
+			c := g.rand.Intn(len(candidates))
+			from := candidates[c]
+			var free []worldmap.Direction
+			for _, direction := range directions {
+				if used.isUsed(from, direction) {
+					continue
+				}
+				if hasOffsets && occupied[spot(g.position(positions[from], direction))] {
+					continue
+				}
+				free = append(free, direction)
+			}
+			if free == nil {
+				candidates[c] = candidates[len(candidates)-1]
+				candidates = candidates[:len(candidates)-1]
+				continue
+			}
+
+			direction := free[g.rand.Intn(len(free))]
+			position := g.position(positions[from], direction)
+			positions = append(positions, position)
+			occupied[spot(position)] = true
+			candidates = append(candidates, i)
+			used.take(from, i, direction)
+			roads = append(roads, road{from: from, to: i, direction: direction, cost: 1})
+			break
+		}
+	}
+	if !hasOffsets {
+		positions = nil
+	}
+	g.addCities(positions)
+	return g.appendRoads(roads)
+}
+
+// scaleFree links each new city to up to scaleFreeRoads cities, chosen
+// with a probability proportional to their number of roads.
+// A city has at most one road per direction, which caps the hubs.
+func (g *generator) scaleFree() error {
+	n := g.opts.Cities
+	directions := g.opts.Directions.Directions()
+	g.addCities(nil)
+
+	used := newSlots(n, g.opts.Directions)
+	// ends lists each city once per road, picking a random
+	// element is picking a city proportionally to its roads
+	var ends []int
+	var roads []road
+	link := func(from, to int, direction worldmap.Direction) {
+		used.take(from, to, direction)
+		ends = append(ends, from, to)
+		roads = append(roads, road{from: from, to: to, direction: direction, cost: 1})
+	}
+	// free returns the directions from a city to the new city
+	free := func(from, to int) (free []worldmap.Direction) {
+		for _, direction := range directions {
+			opposite, _ := g.opts.Directions.Opposite(direction)
+			if !used.isUsed(from, direction) && !used.isUsed(to, opposite) {
+				free = append(free, direction)
+			}
+		}
+		return
+	}
+
+	for i := 1; i < n; i++ {
+		// The new city is already in ends after its first road
+		linked := map[int]bool{i: true}
+		for r := 0; r < scaleFreeRoads && r < i; r++ {
+			for attempt := 0; attempt < 32; attempt++ {
+				var from int
+				if len(ends) == 0 || attempt >= 16 {
+					from = g.rand.Intn(i)
+				} else {
+					from = ends[g.rand.Intn(len(ends))]
+				}
+				if linked[from] {
+					continue
+				}
+				if options := free(from, i); options != nil {
+					link(from, i, options[g.rand.Intn(len(options))])
+					linked[from] = true
+					break
+				}
+			}
+		}
+		if len(linked) == 1 {
+			// Every city close to the new one is full, link the new city
+			// to the newest city with a free direction, which always exists
+			for from := i - 1; from >= 0; from-- {
+				if options := free(from, i); options != nil {
+					link(from, i, options[g.rand.Intn(len(options))])
+					break
+				}
+			}
+		}
+	}
+	return g.appendRoads(roads)
+}
+
+// position returns the position one road away in the direction
+func (g *generator) position(from worldmap.Vector, direction worldmap.Direction) worldmap.Vector {
+	offset, _ := g.opts.Directions.Offset(direction)
+	return worldmap.Vector{X: from.X + offset.X, Y: from.Y + offset.Y, Z: from.Z + offset.Z}
+}
+
+// spot rounds the position to compare positions with rounding errors
+func spot(v worldmap.Vector) [3]int64 {
+	return [3]int64{int64(math.Round(v.X * 1e6)), int64(math.Round(v.Y * 1e6)), int64(math.Round(v.Z * 1e6))}
+}
+
+// slots tracks the directions of the cities which have a road
+type slots struct {
+	ds   *worldmap.DirectionSystem
+	used []map[worldmap.Direction]bool
+}
+
+func newSlots(n int, ds *worldmap.DirectionSystem) *slots {
+	s := &slots{ds: ds, used: make([]map[worldmap.Direction]bool, n)}
+	for i := range s.used {
+		s.used[i] = make(map[worldmap.Direction]bool)
+	}
+	return s
+}
+
+// isUsed checks if the direction of the city has a road
+func (s *slots) isUsed(c int, direction worldmap.Direction) bool {
+	return s.used[c][direction]
+}
+
+// take marks the direction of from and its opposite of to as used
+func (s *slots) take(from, to int, direction worldmap.Direction) {
+	opposite, _ := s.ds.Opposite(direction)
+	s.used[from][direction] = true
+	s.used[to][opposite] = true
+}
+
+func (g *generator) appendRoads(roads []road) error {
+	for _, r := range roads {
+		if err := g.worldMap.AppendRoad(city(r.from), city(r.to), r.direction, worldmap.Road{Cost: r.cost}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unionFind tracks the connected groups of cities
+type unionFind []int
+
+func newUnionFind(n int) unionFind {
+	u := make(unionFind, n)
+	for i := range u {
+		u[i] = i
+	}
+	return u
+}
+
+func (u unionFind) find(i int) int {
+	for u[i] != i {
+		u[i] = u[u[i]]
+		i = u[i]
+	}
+	return i
+}
+
+// union joins the groups of i and j, it is false if already joined
+func (u unionFind) union(i, j int) bool {
+	i, j = u.find(i), u.find(j)
+	if i == j {
+		return false
+	}
+	u[i] = j
+	return true
+}
@@ -0,0 +1,109 @@
+package generate_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	generror "github.com/harry-hov/alien-invasion/error"
+	"github.com/harry-hov/alien-invasion/generate"
+	"github.com/harry-hov/alien-invasion/worldmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connected checks that every city can be reached from the first one
+func connected(worldMap *worldmap.WorldMap) bool {
+	cities := worldMap.GetCities()
+	seen := map[worldmap.City]bool{cities[0]: true}
+	queue := []worldmap.City{cities[0]}
+	for len(queue) > 0 {
+		for _, c := range worldMap.GetConnectedCities(queue[0]) {
+			if !seen[c] {
+				seen[c] = true
+				queue = append(queue, c)
+			}
+		}
+		queue = queue[1:]
+	}
+	return len(seen) == len(cities)
+}
+
+func TestGenerate(t *testing.T) {
+	for _, shape := range generate.Shapes {
+		for _, n := range []int{4, 5, 17, 100} {
+			name := fmt.Sprintf("%v/%v", shape, n)
+			opts := generate.Options{Shape: shape, Cities: n, Seed: int64(n), Coordinates: true}
+			worldMap, err := generate.Generate(opts)
+			require.Nil(t, err, name)
+			assert.Equal(t, n, len(worldMap.GetCities()), name)
+			assert.True(t, connected(worldMap), name)
+			if shape != generate.ShapeScaleFree {
+				assert.Nil(t, worldMap.CheckGeography(), name)
+			}
+
+			// Generated worlds are valid world files
+			var buf bytes.Buffer
+			_, err = worldMap.WriteTo(&buf)
+			require.Nil(t, err, name)
+			expected := buf.String()
+			reread, err := worldmap.InitWorldMap(&buf)
+			require.Nil(t, err, name)
+			assert.ElementsMatch(t, worldMap.GetCities(), reread.GetCities(), name)
+
+			// The same options generate the same world
+			again, err := generate.Generate(opts)
+			require.Nil(t, err, name)
+			buf.Reset()
+			_, err = again.WriteTo(&buf)
+			require.Nil(t, err, name)
+			assert.Equal(t, expected, buf.String(), name)
+		}
+	}
+}
+
+func TestGenerateDirections(t *testing.T) {
+	for _, ds := range []*worldmap.DirectionSystem{worldmap.Diagonal, worldmap.Vertical, worldmap.Hex} {
+		for _, shape := range []generate.Shape{generate.ShapeTree, generate.ShapeScaleFree} {
+			worldMap, err := generate.Generate(generate.Options{Shape: shape, Cities: 200, Seed: 1, Directions: ds, Coordinates: true})
+			require.Nil(t, err, ds.Name())
+			assert.Equal(t, ds, worldMap.GetDirectionSystem())
+			assert.True(t, connected(worldMap), ds.Name())
+			if shape == generate.ShapeTree {
+				assert.Nil(t, worldMap.CheckGeography(), ds.Name())
+			}
+		}
+	}
+
+	_, err := generate.Generate(generate.Options{Shape: generate.ShapeGrid, Cities: 10, Directions: worldmap.Hex})
+	assert.True(t, errors.Is(err, generror.ErrInvalidShape))
+	worldMap, err := generate.Generate(generate.Options{Shape: generate.ShapeGrid, Cities: 10, Directions: worldmap.Vertical})
+	require.Nil(t, err)
+	assert.Nil(t, worldMap.CheckGeography())
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	_, err := generate.Generate(generate.Options{Shape: "star", Cities: 10})
+	assert.True(t, errors.Is(err, generror.ErrInvalidShape))
+	_, err = generate.Generate(generate.Options{Shape: generate.ShapeGrid})
+	assert.True(t, errors.Is(err, generror.ErrInvalidCityCount))
+	_, err = generate.Generate(generate.Options{Shape: generate.ShapeRing, Cities: 3})
+	assert.True(t, errors.Is(err, generror.ErrInvalidCityCount))
+
+	worldMap, err := generate.Generate(generate.Options{Shape: generate.ShapeTree, Cities: 1})
+	require.Nil(t, err)
+	assert.Equal(t, []worldmap.City{"city-0"}, worldMap.GetCities())
+}
+
+func BenchmarkGenerate(b *testing.B) {
+	for _, shape := range generate.Shapes {
+		b.Run(string(shape), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := generate.Generate(generate.Options{Shape: shape, Cities: 10000, Seed: int64(i)}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
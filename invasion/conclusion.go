@@ -0,0 +1,78 @@
+package invasion
+
+import (
+	"fmt"
+
+	"github.com/harry-hov/alien-invasion/worldmap"
+)
+
+// Reason is why an invasion came to an end
+type Reason int
+
+const (
+	Unfinished Reason = iota
+	MaxMoves
+	AllCitiesDestroyed
+	AllAliensDead
+	SingleSurvivor
+	AllTrapped
+)
+
+var reasonNames = map[Reason]string{
+	Unfinished:         "unfinished",
+	MaxMoves:           "max_moves",
+	AllCitiesDestroyed: "all_cities_destroyed",
+	AllAliensDead:      "all_aliens_dead",
+	SingleSurvivor:     "single_survivor",
+	AllTrapped:         "all_trapped",
+}
+
+// String returns the name of the reason
+// e.g SingleSurvivor.String() == "single_survivor"
+func (r Reason) String() string {
+	if name, ok := reasonNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("reason(%d)", int(r))
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (r Reason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (r *Reason) UnmarshalText(text []byte) error {
+	for reason, name := range reasonNames {
+		if name == string(text) {
+			*r = reason
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown reason (%s)", text)
+}
+
+// Conclusion is the outcome of an invasion
+type Conclusion struct {
+	Reason Reason         `json:"reason"`
+	Winner worldmap.Alien `json:"winner,omitempty"`
+	Moves  int            `json:"moves"`
+}
+
+// String returns the conclusion in human readable format
+// e.g "alien (alien-6) won"
+func (c Conclusion) String() string {
+	switch c.Reason {
+	case MaxMoves:
+		return "exceeds maximum moves"
+	case AllCitiesDestroyed:
+		return "all cities destroyed"
+	case AllAliensDead:
+		return "all aliens died"
+	case SingleSurvivor:
+		return fmt.Sprintf("alien (%v) won", c.Winner)
+	case AllTrapped:
+		return "all aliens trapped"
+	}
+	return c.Reason.String()
+}
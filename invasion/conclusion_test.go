@@ -0,0 +1,31 @@
+package invasion_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/harry-hov/alien-invasion/invasion"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConclusionString(t *testing.T) {
+	assert.Equal(t, "exceeds maximum moves", invasion.Conclusion{Reason: invasion.MaxMoves}.String())
+	assert.Equal(t, "all cities destroyed", invasion.Conclusion{Reason: invasion.AllCitiesDestroyed}.String())
+	assert.Equal(t, "all aliens died", invasion.Conclusion{Reason: invasion.AllAliensDead}.String())
+	assert.Equal(t, "alien (alien-6) won", invasion.Conclusion{Reason: invasion.SingleSurvivor, Winner: "alien-6"}.String())
+	assert.Equal(t, "all aliens trapped", invasion.Conclusion{Reason: invasion.AllTrapped}.String())
+	assert.Equal(t, "single_survivor", invasion.SingleSurvivor.String())
+}
+
+func TestConclusionJSON(t *testing.T) {
+	out, err := json.Marshal(invasion.Conclusion{Reason: invasion.SingleSurvivor, Winner: "alien-6", Moves: 12})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"reason":"single_survivor","winner":"alien-6","moves":12}`, string(out))
+}
+
+func TestReasonUnmarshalText(t *testing.T) {
+	var c invasion.Conclusion
+	assert.Nil(t, json.Unmarshal([]byte(`{"reason":"all_trapped","moves":3}`), &c))
+	assert.Equal(t, invasion.Conclusion{Reason: invasion.AllTrapped, Moves: 3}, c)
+	assert.NotNil(t, json.Unmarshal([]byte(`{"reason":"unknown"}`), &c))
+}
@@ -0,0 +1,71 @@
+package invasion
+
+import (
+	"fmt"
+
+	inverror "github.com/harry-hov/alien-invasion/error"
+	"github.com/harry-hov/alien-invasion/worldmap"
+)
+
+const (
+	DefaultMaxMoves       = 10000
+	DefaultFightThreshold = 2
+)
+
+// Config holds the rules of an invasion
+type Config struct {
+	// MaxMoves is the number of moves after which the invasion ends
+	MaxMoves int `json:"max_moves"`
+	// FightThreshold is the minimum number of aliens in a city to trigger a fight
+	FightThreshold int `json:"fight_threshold"`
+	// StopAtSingleSurvivor ends the invasion when only one alien is alive
+	StopAtSingleSurvivor bool `json:"stop_at_single_survivor"`
+	// Shards draws the moves of the aliens concurrently in that many
+	// shards (see worldmap.ParallelRandWalkAlien), 0 draws them serially.
+	// The same seed gives different invasions for different shards.
+	Shards int `json:"shards,omitempty"`
+	// Strategies are the move strategies given to the aliens in turn
+	// when the invasion begins (see worldmap.ParseStrategy),
+	// all aliens walk randomly if empty
+	Strategies []string `json:"strategies,omitempty"`
+}
+
+// DefaultConfig returns the default rules of an invasion
+func DefaultConfig() Config {
+	return Config{
+		MaxMoves:             DefaultMaxMoves,
+		FightThreshold:       DefaultFightThreshold,
+		StopAtSingleSurvivor: true,
+	}
+}
+
+// Validate checks if config is valid
+func (c Config) Validate() error {
+	if c.MaxMoves < 1 {
+		return inverror.Wrap(inverror.ErrInvalidConfig, fmt.Sprintf("max moves (%v) must be positive", c.MaxMoves))
+	}
+	if c.FightThreshold < 2 {
+		return inverror.Wrap(inverror.ErrInvalidConfig, fmt.Sprintf("fight threshold (%v) must be at least 2", c.FightThreshold))
+	}
+	if c.Shards < 0 {
+		return inverror.Wrap(inverror.ErrInvalidConfig, fmt.Sprintf("shards (%v) cannot be negative", c.Shards))
+	}
+	if _, err := c.MoveStrategies(nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+// MoveStrategies parses the move strategies of the aliens invading
+// a WorldMap of direction system ds (see worldmap.ParseStrategy)
+func (c Config) MoveStrategies(ds *worldmap.DirectionSystem) ([]worldmap.MoveStrategy, error) {
+	strategies := make([]worldmap.MoveStrategy, 0, len(c.Strategies))
+	for _, spec := range c.Strategies {
+		strategy, err := worldmap.ParseStrategy(spec, ds)
+		if err != nil {
+			return nil, err
+		}
+		strategies = append(strategies, strategy)
+	}
+	return strategies, nil
+}
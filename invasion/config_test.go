@@ -0,0 +1,39 @@
+package invasion_test
+
+import (
+	"testing"
+
+	"github.com/harry-hov/alien-invasion/invasion"
+	"github.com/harry-hov/alien-invasion/worldmap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigValidate(t *testing.T) {
+	assert.Nil(t, invasion.DefaultConfig().Validate())
+
+	config := invasion.DefaultConfig()
+	config.MaxMoves = 0
+	assert.NotNil(t, config.Validate())
+
+	config = invasion.DefaultConfig()
+	config.FightThreshold = 1
+	assert.NotNil(t, config.Validate())
+
+	config = invasion.DefaultConfig()
+	config.Shards = -1
+	assert.NotNil(t, config.Validate())
+
+	config = invasion.DefaultConfig()
+	config.Strategies = []string{"lazy", "stay-put:0.5"}
+	assert.Nil(t, config.Validate())
+	config.Strategies = append(config.Strategies, "unknown")
+	assert.NotNil(t, config.Validate())
+
+	// Directions are only known once the WorldMap is read
+	config.Strategies = []string{"biased:up=2"}
+	assert.Nil(t, config.Validate())
+	_, err := config.MoveStrategies(worldmap.Compass)
+	assert.NotNil(t, err)
+	config.Strategies = []string{"biased:nort=2"}
+	assert.NotNil(t, config.Validate())
+}
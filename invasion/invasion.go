@@ -2,20 +2,21 @@ package invasion
 
 import (
 	"fmt"
+	"math/rand"
 
 	"github.com/harry-hov/alien-invasion/utils"
 	"github.com/harry-hov/alien-invasion/worldmap"
 )
 
-const maxMoves = 10000
-
-type Conclusion string
-
 type Invasion struct {
 	worldMap   *worldmap.WorldMap
 	move       int
 	finished   bool
 	conclusion Conclusion
+	config     Config
+	sink       EventSink
+	source     rand.Source
+	trapped    map[worldmap.Alien]bool
 }
 
 // GetRelease returns WorldMap
@@ -28,6 +29,11 @@ func (i *Invasion) GetCurrentMove() int {
 	return i.move
 }
 
+// GetConfig returns the Config of invasion
+func (i *Invasion) GetConfig() Config {
+	return i.config
+}
+
 // GetRelease returns the Conclusion of invasion
 func (i *Invasion) Conclusion() Conclusion {
 	return i.conclusion
@@ -53,67 +59,198 @@ func (i *Invasion) SetConclusion(c Conclusion) {
 	i.conclusion = c
 }
 
+// SetConfig sets the rules of the invasion
+func (i *Invasion) SetConfig(c Config) {
+	i.config = c
+}
+
+// SetEventSink sets the EventSink notified of invasion events
+func (i *Invasion) SetEventSink(sink EventSink) {
+	i.sink = sink
+}
+
 // InitInvasion Unleases aliens on WorldMap and returns Invasion
-func InitInvasion(worldMap *worldmap.WorldMap, aliens uint) *Invasion {
+// played by the rules of config (see DefaultConfig).
+// Aliens get the strategies of config in turn, in the order they were unleashed.
+// If source is not nil, it is used for every random choice of the
+// invasion, so the same seed always produces the same invasion.
+// Use an rng.Source to be able to take a Snapshot of the invasion.
+// If sink is not nil, it is notified of every invasion event.
+// It returns an error if config is invalid (see Config.Validate),
+// or if its strategies do not fit the direction system of worldMap.
+func InitInvasion(worldMap *worldmap.WorldMap, aliens uint, config Config, source rand.Source, sink EventSink) (*Invasion, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	strategies, err := config.MoveStrategies(worldMap.GetDirectionSystem())
+	if err != nil {
+		return nil, err
+	}
 	invasion := &Invasion{
 		worldMap: worldMap,
 		move:     0,
+		config:   config,
+		sink:     sink,
+		source:   source,
+	}
+	if source != nil {
+		invasion.worldMap.SetRand(rand.New(source))
 	}
 	invasion.worldMap.UnleaseNAliens(aliens)
-	return invasion
+
+	for n, alien := range invasion.worldMap.GetAlienList() {
+		if len(strategies) > 0 {
+			if err := invasion.worldMap.SetAlienStrategy(alien, strategies[n%len(strategies)]); err != nil {
+				return nil, err
+			}
+		}
+		city, _ := invasion.worldMap.GetAlienCity(alien)
+		invasion.getSink().AlienUnleashed(alien, city)
+	}
+	invasion.notifyTrapped()
+	return invasion, nil
+}
+
+// getSink returns the EventSink, ignoring events if none is set
+func (i *Invasion) getSink() EventSink {
+	if i.sink == nil {
+		return NopSink{}
+	}
+	return i.sink
+}
+
+// notifyTrapped notifies the sink of aliens trapped since the last call
+// Aliens are only notified the first time they are trapped.
+func (i *Invasion) notifyTrapped() {
+	if i.trapped == nil {
+		i.trapped = make(map[worldmap.Alien]bool)
+	}
+	for _, alien := range i.worldMap.TakeNewlyTrapped() {
+		if !i.trapped[alien] {
+			i.trapped[alien] = true
+			city, _ := i.worldMap.GetAlienCity(alien)
+			i.getSink().AlienTrapped(i.move, alien, city)
+		}
+	}
 }
 
 // MakeMove reallocate aliens to random connected city
 // and increment the current move count
+// With Config.Shards, the moves are drawn concurrently.
 func (i *Invasion) MakeMove() {
-	i.worldMap.RandWalkAlien()
+	var moves []worldmap.Move
+	if i.config.Shards > 0 {
+		moves = i.worldMap.ParallelRandWalkAlien(i.config.Shards)
+	} else {
+		moves = i.worldMap.RandWalkAlien()
+	}
 	i.move++
+	for _, m := range moves {
+		i.getSink().AlienMoved(i.move, m)
+	}
+	i.notifyTrapped()
+}
+
+// ApplyMoves makes the given moves instead of random ones
+// and increment the current move count, e.g to replay an invasion
+// Travelling aliens get one move closer to their city first.
+func (i *Invasion) ApplyMoves(moves []worldmap.Move) error {
+	i.move++
+	i.worldMap.AdvanceTransit()
+	for _, m := range moves {
+		if err := i.worldMap.MoveAlien(m.Alien, m.To); err != nil {
+			return err
+		}
+		i.getSink().AlienMoved(i.move, m)
+	}
+	i.notifyTrapped()
+	return nil
 }
 
 // IsFinished checks if invasion is finished
 // If finished, it sets conclusion and return
 func (i *Invasion) IsFinished() bool {
-	if i.move >= maxMoves {
-		i.finished = true
-		i.conclusion = Conclusion("exceeds maximum moves")
+	if i.finished {
 		return i.finished
 	}
-	if i.worldMap.GetCities() == nil {
-		i.finished = true
-		i.conclusion = Conclusion("all cities destroyed")
-		return i.finished
+
+	if i.move >= i.config.MaxMoves {
+		return i.finish(Conclusion{Reason: MaxMoves})
+	}
+	if i.worldMap.CityCount() == 0 {
+		return i.finish(Conclusion{Reason: AllCitiesDestroyed})
 	}
 
-	aliens := i.worldMap.GetAlienList()
-	if aliens == nil {
-		i.finished = true
-		i.conclusion = Conclusion("all aliens died")
-		return i.finished
+	aliens := i.worldMap.AlienCount()
+	if aliens == 0 {
+		return i.finish(Conclusion{Reason: AllAliensDead})
 	}
-	if len(aliens) == 1 {
-		i.finished = true
-		i.conclusion = Conclusion(fmt.Sprintf("alien (%v) won", aliens[0]))
-		return i.finished
+	if aliens == 1 && i.config.StopAtSingleSurvivor {
+		return i.finish(Conclusion{Reason: SingleSurvivor, Winner: i.worldMap.GetAlienList()[0]})
 	}
 
-	if trappedAliens := i.worldMap.GetTrappedAlienCount(); (uint(len(aliens)) - trappedAliens) == 0 {
-		i.finished = true
-		i.conclusion = Conclusion("all aliens trapped")
-		return i.finished
+	if trappedAliens := i.worldMap.GetTrappedAlienCount(); (uint(aliens) - trappedAliens) == 0 {
+		return i.finish(Conclusion{Reason: AllTrapped})
 	}
 
 	return i.finished
 }
 
-// Fight makes the aliens fight if city has more than 2 aliens.
-// In process, destroys city and Kill aliens on the destroyed city.
-func (i *Invasion) Fight() {
-	for city, aliens := range i.worldMap.GetAliensByCity() {
-		if len(aliens) >= 2 {
-			i.worldMap.DestroyCity(city)
-			i.worldMap.KillAliens(aliens)
+// finish sets the conclusion and notifies the sink
+func (i *Invasion) finish(c Conclusion) bool {
+	c.Moves = i.move
+	i.finished = true
+	i.conclusion = c
+	i.getSink().InvasionFinished(i)
+	return i.finished
+}
+
+// Destruction records a city destroyed in a fight
+type Destruction struct {
+	Move   int              `json:"move"`
+	City   worldmap.City    `json:"city"`
+	Aliens []worldmap.Alien `json:"aliens"`
+	// Travellers are the aliens killed on their way to the city
+	Travellers []worldmap.Alien `json:"travellers,omitempty"`
+}
 
-			fmt.Println(fmt.Sprintf("%v has been destroyed by %v!", city, utils.PrettyJoinAliens(aliens)))
+// String returns the destruction in human readable format
+func (d Destruction) String() string {
+	out := fmt.Sprintf("%v has been destroyed by %v!", d.City, utils.PrettyJoinAliens(d.Aliens))
+	switch len(d.Travellers) {
+	case 0:
+	case 1:
+		out += fmt.Sprintf(" %v died on the way.", d.Travellers[0])
+	default:
+		out += fmt.Sprintf(" %v died on the way.", utils.PrettyJoinAliens(d.Travellers))
+	}
+	return out
+}
+
+// Fight makes the aliens fight if city has at least
+// Config.FightThreshold aliens. Aliens travelling cannot fight.
+// In process, destroys city and Kill aliens on the destroyed city
+// and on their way to it.
+// Cities are visited in the order they were added to the WorldMap.
+// It returns the destructions caused by the fights.
+func (i *Invasion) Fight() (destructions []Destruction) {
+	for _, city := range i.worldMap.GetCrowdedCities(i.config.FightThreshold) {
+		aliens := i.worldMap.GetCityAliens(city)
+		travellers := i.worldMap.GetAliensTravellingTo(city)
+		i.worldMap.DestroyCity(city)
+		i.worldMap.KillAliens(aliens)
+		i.worldMap.KillAliens(travellers)
+
+		destruction := Destruction{Move: i.move, City: city, Aliens: aliens, Travellers: travellers}
+		destructions = append(destructions, destruction)
+		i.getSink().CityDestroyed(destruction)
+		i.getSink().AliensKilled(i.move, aliens)
+		if travellers != nil {
+			i.getSink().AliensKilled(i.move, travellers)
 		}
 	}
+	if destructions != nil {
+		i.notifyTrapped()
+	}
+	return
 }
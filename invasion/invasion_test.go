@@ -1,9 +1,14 @@
 package invasion_test
 
 import (
+	"errors"
+	"fmt"
+	"math/rand"
 	"strings"
 	"testing"
 
+	inverror "github.com/harry-hov/alien-invasion/error"
+	"github.com/harry-hov/alien-invasion/generate"
 	"github.com/harry-hov/alien-invasion/invasion"
 	"github.com/harry-hov/alien-invasion/worldmap"
 	"github.com/stretchr/testify/assert"
@@ -14,6 +19,11 @@ const worldMapInput string = `Foo north=Bar west=Baz south=Qu-ux
 Bar south=Foo west=Bee
 `
 
+// weightedWorldMapInput keeps aliens travelling for several moves
+const weightedWorldMapInput string = `Foo north=Bar:3 west=Baz south=Qu-ux:2
+Bar south=Foo:3 west=Bee
+`
+
 func ResetInvasion(i *invasion.Invasion) {
 	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
 	if err != nil {
@@ -23,13 +33,31 @@ func ResetInvasion(i *invasion.Invasion) {
 	i.SetWorldMap(worldMap)
 	i.SetMove(0)
 	i.SetFinished(false)
-	i.SetConclusion("")
+	i.SetConclusion(invasion.Conclusion{})
+	i.SetConfig(invasion.DefaultConfig())
 }
 
 func TestInitInvasion(t *testing.T) {
 	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
 	require.Nil(t, err)
-	assert.NotPanics(t, func() { invasion.InitInvasion(worldMap, 8) })
+	assert.NotPanics(t, func() { invasion.InitInvasion(worldMap, 8, invasion.DefaultConfig(), nil, nil) })
+
+	// Invalid configs are rejected before aliens are unleashed
+	worldMap, err = worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	require.Nil(t, err)
+	in, err := invasion.InitInvasion(worldMap, 8, invasion.Config{}, nil, nil)
+	assert.Nil(t, in)
+	assert.True(t, errors.Is(err, inverror.ErrInvalidConfig))
+	assert.Nil(t, worldMap.GetAlienList())
+
+	// So are strategies not fitting the WorldMap
+	config := invasion.DefaultConfig()
+	for _, strategies := range [][]string{{"bogus"}, {"lazy", "biased:up=2"}} {
+		config.Strategies = strategies
+		_, err = invasion.InitInvasion(worldMap, 8, config, nil, nil)
+		assert.True(t, errors.Is(err, inverror.ErrInvalidStrategy), strategies)
+		assert.Nil(t, worldMap.GetAlienList(), strategies)
+	}
 }
 
 func TestSetAndGetGetWorldMap(t *testing.T) {
@@ -50,14 +78,15 @@ func TestSetAndGetCurrentMove(t *testing.T) {
 func TestSetAndGetConclusion(t *testing.T) {
 	var in invasion.Invasion
 
-	in.SetConclusion("concluded")
-	assert.Equal(t, invasion.Conclusion("concluded"), in.Conclusion())
+	in.SetConclusion(invasion.Conclusion{Reason: invasion.AllTrapped, Moves: 3})
+	assert.Equal(t, invasion.Conclusion{Reason: invasion.AllTrapped, Moves: 3}, in.Conclusion())
 }
 
 func TestMakeMove(t *testing.T) {
 	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
 	require.Nil(t, err)
-	i := invasion.InitInvasion(worldMap, 8)
+	i, err := invasion.InitInvasion(worldMap, 8, invasion.DefaultConfig(), nil, nil)
+	require.Nil(t, err)
 	assert.Equal(t, 0, i.GetCurrentMove())
 	i.MakeMove()
 	assert.Equal(t, 1, i.GetCurrentMove())
@@ -67,13 +96,14 @@ func TestIsFinished(t *testing.T) {
 	var in *invasion.Invasion
 	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
 	require.Nil(t, err)
-	assert.NotPanics(t, func() { in = invasion.InitInvasion(worldMap, 8) })
+	assert.NotPanics(t, func() { in, err = invasion.InitInvasion(worldMap, 8, invasion.DefaultConfig(), nil, nil) })
+	require.Nil(t, err)
 
 	// Test moves exceeds limit
 	in.SetMove(10000)
 	res := in.IsFinished()
 	assert.Equal(t, true, res)
-	assert.Equal(t, invasion.Conclusion("exceeds maximum moves"), in.Conclusion())
+	assert.Equal(t, invasion.Conclusion{Reason: invasion.MaxMoves, Moves: 10000}, in.Conclusion())
 
 	ResetInvasion(in)
 
@@ -82,7 +112,7 @@ func TestIsFinished(t *testing.T) {
 		in.GetWorldMap().DestroyCity(city)
 	}
 	assert.Equal(t, true, in.IsFinished())
-	assert.Equal(t, invasion.Conclusion("all cities destroyed"), in.Conclusion())
+	assert.Equal(t, invasion.Conclusion{Reason: invasion.AllCitiesDestroyed}, in.Conclusion())
 
 	ResetInvasion(in)
 
@@ -90,7 +120,7 @@ func TestIsFinished(t *testing.T) {
 	aliens := in.GetWorldMap().GetAlienList()
 	in.GetWorldMap().KillAliens(aliens)
 	assert.Equal(t, true, in.IsFinished())
-	assert.Equal(t, invasion.Conclusion("all aliens died"), in.Conclusion())
+	assert.Equal(t, invasion.Conclusion{Reason: invasion.AllAliensDead}, in.Conclusion())
 
 	ResetInvasion(in)
 
@@ -98,7 +128,8 @@ func TestIsFinished(t *testing.T) {
 	aliens = in.GetWorldMap().GetAlienList()
 	in.GetWorldMap().KillAliens(aliens[1:])
 	assert.Equal(t, true, in.IsFinished())
-	assert.True(t, strings.HasSuffix(string(in.Conclusion()), "won"))
+	assert.Equal(t, invasion.SingleSurvivor, in.Conclusion().Reason)
+	assert.Equal(t, aliens[0], in.Conclusion().Winner)
 
 	ResetInvasion(in)
 
@@ -107,7 +138,7 @@ func TestIsFinished(t *testing.T) {
 		in.GetWorldMap().DestroyCity(city)
 	}
 	assert.Equal(t, true, in.IsFinished())
-	assert.Equal(t, invasion.Conclusion("all aliens trapped"), in.Conclusion())
+	assert.Equal(t, invasion.Conclusion{Reason: invasion.AllTrapped}, in.Conclusion())
 }
 
 func TestFight(t *testing.T) {
@@ -115,8 +146,276 @@ func TestFight(t *testing.T) {
 	var in *invasion.Invasion
 	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
 	require.Nil(t, err)
-	assert.NotPanics(t, func() { in = invasion.InitInvasion(worldMap, 1000) })
-	in.Fight()
+	assert.NotPanics(t, func() { in, err = invasion.InitInvasion(worldMap, 1000, invasion.DefaultConfig(), nil, nil) })
+	require.Nil(t, err)
+	destructions := in.Fight()
 	assert.Equal(t, 0, len(in.GetWorldMap().GetAlienList()))
 	assert.Equal(t, 0, len(in.GetWorldMap().GetCities()))
+	require.Equal(t, 2, len(destructions))
+	assert.Equal(t, worldmap.City("Foo"), destructions[0].City)
+	assert.Equal(t, worldmap.City("Bar"), destructions[1].City)
+	assert.Equal(t, 1000, len(destructions[0].Aliens)+len(destructions[1].Aliens))
+}
+
+func TestDestructionString(t *testing.T) {
+	d := invasion.Destruction{City: "Foo", Aliens: []worldmap.Alien{"alien-0", "alien-1"}}
+	assert.Equal(t, "Foo has been destroyed by alien-0 and alien-1!", d.String())
+}
+
+func TestSeededInvasion(t *testing.T) {
+	run := func(seed int64, shards int) *invasion.Invasion {
+		worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+		require.Nil(t, err)
+		config := invasion.DefaultConfig()
+		config.Shards = shards
+		in, err := invasion.InitInvasion(worldMap, 4, config, rand.NewSource(seed), nil)
+		require.Nil(t, err)
+		for !in.IsFinished() {
+			in.MakeMove()
+			in.Fight()
+		}
+		return in
+	}
+
+	// Moves drawn in shards are as reproducible as serial ones
+	for _, shards := range []int{0, 1, 3} {
+		first, second := run(42, shards), run(42, shards)
+		assert.Equal(t, first.Conclusion(), second.Conclusion())
+		assert.Equal(t, first.GetCurrentMove(), second.GetCurrentMove())
+		assert.Equal(t, first.GetWorldMap().GetCities(), second.GetWorldMap().GetCities())
+		assert.Equal(t, first.GetWorldMap().GetAliens(), second.GetWorldMap().GetAliens())
+	}
+}
+
+func TestStrategyInvasion(t *testing.T) {
+	run := func(seed int64, shards int) *invasion.Invasion {
+		worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+		require.Nil(t, err)
+		config := invasion.DefaultConfig()
+		config.Shards = shards
+		config.Strategies = []string{"avoid-occupied", "seek-nearest", "follow-wall"}
+		in, err := invasion.InitInvasion(worldMap, 4, config, rand.NewSource(seed), nil)
+		require.Nil(t, err)
+		for !in.IsFinished() {
+			in.MakeMove()
+			in.Fight()
+		}
+		return in
+	}
+
+	// Aliens get the strategies in turn
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	require.Nil(t, err)
+	config := invasion.DefaultConfig()
+	config.Strategies = []string{"lazy", "stay-put:1"}
+	in, err := invasion.InitInvasion(worldMap, 3, config, rand.NewSource(1), nil)
+	require.Nil(t, err)
+	var strategies []string
+	for _, alien := range in.GetWorldMap().GetAlienList() {
+		strategies = append(strategies, in.GetWorldMap().GetAlienStrategy(alien).String())
+	}
+	assert.Equal(t, []string{"lazy", "stay-put:1", "lazy"}, strategies)
+
+	for _, shards := range []int{0, 3} {
+		first, second := run(7, shards), run(7, shards)
+		assert.Equal(t, first.Conclusion(), second.Conclusion())
+		assert.Equal(t, first.GetCurrentMove(), second.GetCurrentMove())
+		assert.Equal(t, first.GetWorldMap().GetAliens(), second.GetWorldMap().GetAliens())
+	}
+}
+
+// recordingSink counts the events of an invasion
+type recordingSink struct {
+	invasion.NopSink
+	unleashed, moved, trapped, destroyed, killed, finished int
+}
+
+func (s *recordingSink) AlienUnleashed(worldmap.Alien, worldmap.City)    { s.unleashed++ }
+func (s *recordingSink) AlienMoved(int, worldmap.Move)                   { s.moved++ }
+func (s *recordingSink) AlienTrapped(int, worldmap.Alien, worldmap.City) { s.trapped++ }
+func (s *recordingSink) CityDestroyed(invasion.Destruction)              { s.destroyed++ }
+func (s *recordingSink) AliensKilled(_ int, aliens []worldmap.Alien)     { s.killed += len(aliens) }
+func (s *recordingSink) InvasionFinished(*invasion.Invasion)             { s.finished++ }
+
+func TestEventSink(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	require.Nil(t, err)
+	sink := &recordingSink{}
+	in, err := invasion.InitInvasion(worldMap, 8, invasion.DefaultConfig(), rand.NewSource(1), sink)
+	require.Nil(t, err)
+	assert.Equal(t, 8, sink.unleashed)
+
+	for !in.IsFinished() {
+		in.MakeMove()
+		in.Fight()
+	}
+	assert.True(t, in.IsFinished())
+
+	assert.Equal(t, 1, sink.finished)
+	assert.Greater(t, sink.moved, 0)
+	assert.Equal(t, 5-len(in.GetWorldMap().GetCities()), sink.destroyed)
+	assert.Equal(t, 8-len(in.GetWorldMap().GetAlienList()), sink.killed)
+	assert.LessOrEqual(t, sink.trapped, 8-sink.killed)
+}
+
+func TestTrappedAtDeadEnd(t *testing.T) {
+	config := invasion.DefaultConfig()
+	config.StopAtSingleSurvivor = false
+
+	// Aliens reaching the end of a one-way road are trapped there,
+	// after the moves of the road
+	for _, input := range []string{`A north->B`, `A north->B:3`} {
+		for seed := int64(0); seed < 4; seed++ {
+			worldMap, err := worldmap.InitWorldMap(strings.NewReader(input))
+			require.Nil(t, err)
+			sink := &recordingSink{}
+			in, err := invasion.InitInvasion(worldMap, 1, config, rand.NewSource(seed), sink)
+			require.Nil(t, err)
+			for !in.IsFinished() {
+				in.MakeMove()
+				in.Fight()
+			}
+			assert.Equal(t, invasion.AllTrapped, in.Conclusion().Reason, input)
+			assert.Equal(t, 1, sink.trapped, input)
+		}
+	}
+}
+
+func TestFightThreshold(t *testing.T) {
+	const worldMapInput string = `Foo north=Bar`
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	require.Nil(t, err)
+	require.Nil(t, worldMap.AddAlien("alien-0", "Foo"))
+	require.Nil(t, worldMap.AddAlien("alien-1", "Foo"))
+
+	config := invasion.DefaultConfig()
+	config.FightThreshold = 3
+	in, err := invasion.InitInvasion(worldMap, 0, config, nil, nil)
+	require.Nil(t, err)
+	assert.Nil(t, in.Fight())
+	assert.Equal(t, 2, len(in.GetWorldMap().GetAlienList()))
+
+	config.FightThreshold = 2
+	in.SetConfig(config)
+	assert.Equal(t, 1, len(in.Fight()))
+	assert.Equal(t, 0, len(in.GetWorldMap().GetAlienList()))
+}
+
+func TestStopAtSingleSurvivor(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	require.Nil(t, err)
+	config := invasion.DefaultConfig()
+	config.StopAtSingleSurvivor = false
+	config.MaxMoves = 5
+	in, err := invasion.InitInvasion(worldMap, 1, config, nil, nil)
+	require.Nil(t, err)
+	assert.False(t, in.IsFinished())
+	for !in.IsFinished() {
+		in.MakeMove()
+		in.Fight()
+	}
+	assert.Equal(t, invasion.Conclusion{Reason: invasion.MaxMoves, Moves: 5}, in.Conclusion())
+}
+
+func TestApplyMoves(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	require.Nil(t, err)
+	require.Nil(t, worldMap.AddAlien("alien-0", "Foo"))
+	sink := &recordingSink{}
+	in, err := invasion.InitInvasion(worldMap, 0, invasion.DefaultConfig(), nil, invasion.MultiSink(sink, invasion.NopSink{}))
+	require.Nil(t, err)
+
+	assert.Nil(t, in.ApplyMoves([]worldmap.Move{{Alien: "alien-0", From: "Foo", To: "Bar"}}))
+	assert.Equal(t, 1, in.GetCurrentMove())
+	assert.Equal(t, 1, sink.moved)
+	assert.NotNil(t, in.ApplyMoves([]worldmap.Move{{Alien: "alien-0", From: "Bar", To: "Baz"}}))
+}
+
+func TestTravelTime(t *testing.T) {
+	const worldMapInput string = `Foo north=Bar:3 west=Baz`
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	require.Nil(t, err)
+	require.Nil(t, worldMap.AddAlien("alien-0", "Foo"))
+	require.Nil(t, worldMap.AddAlien("alien-1", "Foo"))
+	require.Nil(t, worldMap.AddAlien("alien-2", "Baz"))
+	sink := &recordingSink{}
+	in, err := invasion.InitInvasion(worldMap, 0, invasion.DefaultConfig(), nil, sink)
+	require.Nil(t, err)
+
+	// Travelling aliens cannot fight
+	require.Nil(t, in.ApplyMoves([]worldmap.Move{{Alien: "alien-0", To: "Bar"}, {Alien: "alien-1", To: "Bar"}}))
+	assert.Nil(t, in.Fight())
+	require.Nil(t, in.ApplyMoves(nil))
+	assert.Nil(t, in.Fight())
+	assert.Equal(t, 1, in.GetWorldMap().GetAlienTransit("alien-0"))
+	assert.False(t, in.IsFinished())
+
+	// They arrive once the road is traveled
+	require.Nil(t, in.ApplyMoves(nil))
+	assert.Equal(t, 3, in.GetCurrentMove())
+	assert.Equal(t, []invasion.Destruction{{Move: 3, City: "Bar", Aliens: []worldmap.Alien{"alien-0", "alien-1"}}}, in.Fight())
+
+	// Aliens travelling to a destroyed city are killed with it
+	worldMap, err = worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	require.Nil(t, err)
+	require.Nil(t, worldMap.AddAlien("alien-0", "Bar"))
+	require.Nil(t, worldMap.AddAlien("alien-1", "Foo"))
+	require.Nil(t, worldMap.AddAlien("alien-2", "Baz"))
+	in, err = invasion.InitInvasion(worldMap, 0, invasion.DefaultConfig(), nil, nil)
+	require.Nil(t, err)
+	require.Nil(t, in.ApplyMoves([]worldmap.Move{{Alien: "alien-0", To: "Foo"}, {Alien: "alien-2", To: "Foo"}}))
+	require.Nil(t, in.ApplyMoves(nil))
+	assert.Equal(t, 1, len(in.Fight()))
+	assert.Nil(t, in.GetWorldMap().GetAlienList())
+}
+
+func TestFightTravellers(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(weightedWorldMapInput))
+	require.Nil(t, err)
+	require.Nil(t, worldMap.AddAlien("alien-0", "Foo"))
+	require.Nil(t, worldMap.AddAlien("alien-1", "Baz"))
+	require.Nil(t, worldMap.AddAlien("alien-2", "Bar"))
+	sink := &recordingSink{}
+	in, err := invasion.InitInvasion(worldMap, 0, invasion.DefaultConfig(), nil, sink)
+	require.Nil(t, err)
+
+	// alien-2 is still on the road from Bar when Foo is destroyed
+	require.Nil(t, in.ApplyMoves([]worldmap.Move{{Alien: "alien-1", To: "Foo"}, {Alien: "alien-2", To: "Foo"}}))
+	destructions := in.Fight()
+	assert.Equal(t, []invasion.Destruction{{
+		Move:       1,
+		City:       "Foo",
+		Aliens:     []worldmap.Alien{"alien-0", "alien-1"},
+		Travellers: []worldmap.Alien{"alien-2"},
+	}}, destructions)
+	assert.Equal(t, "Foo has been destroyed by alien-0 and alien-1! alien-2 died on the way.", destructions[0].String())
+	assert.Equal(t, 3, sink.killed)
+	assert.Nil(t, in.GetWorldMap().GetAlienList())
+}
+
+// BenchmarkLargeInvasion plays a whole invasion of 100k aliens
+// on a grid of 1M cities with the default config, drawing the moves
+// serially and in shards
+func BenchmarkLargeInvasion(b *testing.B) {
+	worldMap, err := generate.Generate(generate.Options{Shape: generate.ShapeGrid, Cities: 1000000, Seed: 1})
+	require.Nil(b, err)
+	for _, shards := range []int{0, 4, 16} {
+		b.Run(fmt.Sprintf("shards-%v", shards), func(b *testing.B) {
+			config := invasion.DefaultConfig()
+			config.Shards = shards
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				clone := worldMap.Clone()
+				b.StartTimer()
+				in, err := invasion.InitInvasion(clone, 100000, config, rand.NewSource(int64(i)), nil)
+				require.Nil(b, err)
+				for !in.IsFinished() {
+					in.MakeMove()
+					in.Fight()
+				}
+				b.ReportMetric(float64(in.GetCurrentMove()), "moves/op")
+				b.ReportMetric(float64(in.GetWorldMap().AlienCount()), "survivors/op")
+			}
+		})
+	}
 }
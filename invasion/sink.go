@@ -0,0 +1,76 @@
+package invasion
+
+import "github.com/harry-hov/alien-invasion/worldmap"
+
+// EventSink receives the events of an invasion as they happen.
+// Library users can plug in their own logging, metrics or UI
+// by implementing it and passing it to InitInvasion.
+type EventSink interface {
+	// AlienUnleashed is called when an alien lands in a city
+	AlienUnleashed(alien worldmap.Alien, city worldmap.City)
+	// AlienMoved is called when an alien walks to a connected city
+	AlienMoved(move int, m worldmap.Move)
+	// AlienTrapped is called once when an alien's city has no roads left
+	AlienTrapped(move int, alien worldmap.Alien, city worldmap.City)
+	// CityDestroyed is called when a fight destroys a city
+	CityDestroyed(d Destruction)
+	// AliensKilled is called when aliens die in a fight
+	AliensKilled(move int, aliens []worldmap.Alien)
+	// InvasionFinished is called once when the invasion comes to a conclusion
+	InvasionFinished(i *Invasion)
+}
+
+// NopSink ignores every event.
+// Embed it to implement only some of the EventSink callbacks.
+type NopSink struct{}
+
+func (NopSink) AlienUnleashed(worldmap.Alien, worldmap.City)    {}
+func (NopSink) AlienMoved(int, worldmap.Move)                   {}
+func (NopSink) AlienTrapped(int, worldmap.Alien, worldmap.City) {}
+func (NopSink) CityDestroyed(Destruction)                       {}
+func (NopSink) AliensKilled(int, []worldmap.Alien)              {}
+func (NopSink) InvasionFinished(*Invasion)                      {}
+
+// multiSink forwards every event to several sinks
+type multiSink []EventSink
+
+// MultiSink returns an EventSink forwarding every event to sinks in order
+func MultiSink(sinks ...EventSink) EventSink {
+	return multiSink(sinks)
+}
+
+func (m multiSink) AlienUnleashed(alien worldmap.Alien, city worldmap.City) {
+	for _, sink := range m {
+		sink.AlienUnleashed(alien, city)
+	}
+}
+
+func (m multiSink) AlienMoved(move int, mv worldmap.Move) {
+	for _, sink := range m {
+		sink.AlienMoved(move, mv)
+	}
+}
+
+func (m multiSink) AlienTrapped(move int, alien worldmap.Alien, city worldmap.City) {
+	for _, sink := range m {
+		sink.AlienTrapped(move, alien, city)
+	}
+}
+
+func (m multiSink) CityDestroyed(d Destruction) {
+	for _, sink := range m {
+		sink.CityDestroyed(d)
+	}
+}
+
+func (m multiSink) AliensKilled(move int, aliens []worldmap.Alien) {
+	for _, sink := range m {
+		sink.AliensKilled(move, aliens)
+	}
+}
+
+func (m multiSink) InvasionFinished(i *Invasion) {
+	for _, sink := range m {
+		sink.InvasionFinished(i)
+	}
+}
@@ -0,0 +1,86 @@
+package invasion
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+
+	inverror "github.com/harry-hov/alien-invasion/error"
+	"github.com/harry-hov/alien-invasion/rng"
+	"github.com/harry-hov/alien-invasion/worldmap"
+)
+
+// SnapshotVersion is the version of the snapshot format
+const SnapshotVersion = 1
+
+// Snapshot is the full state of an in-progress invasion
+type Snapshot struct {
+	Version int                `json:"version"`
+	Move    int                `json:"move"`
+	Config  Config             `json:"config"`
+	Rand    rng.State          `json:"rand"`
+	World   *worldmap.WorldMap `json:"world"`
+}
+
+// Snapshot returns the state of the invasion
+// The invasion must have been initialized with an rng.Source
+func (i *Invasion) Snapshot() (*Snapshot, error) {
+	source, ok := i.source.(*rng.Source)
+	if !ok {
+		return nil, inverror.Wrap(inverror.ErrInvalidSnapshot, "random source cannot be saved")
+	}
+	return &Snapshot{
+		Version: SnapshotVersion,
+		Move:    i.move,
+		Config:  i.config,
+		Rand:    source.State(),
+		World:   i.worldMap.Clone(),
+	}, nil
+}
+
+// Resume returns the invasion in the state of the snapshot
+// If sink is not nil, it is notified of every invasion event.
+func Resume(s *Snapshot, sink EventSink) (*Invasion, error) {
+	if s.Version != SnapshotVersion {
+		return nil, inverror.Wrap(inverror.ErrInvalidSnapshot, fmt.Sprintf("unsupported version (%v)", s.Version))
+	}
+	if s.World == nil {
+		return nil, inverror.Wrap(inverror.ErrInvalidSnapshot, "missing world")
+	}
+	if err := s.Config.Validate(); err != nil {
+		return nil, err
+	}
+
+	source := rng.Restore(s.Rand)
+	invasion := &Invasion{
+		worldMap: s.World.Clone(),
+		move:     s.Move,
+		config:   s.Config,
+		sink:     sink,
+		source:   source,
+		trapped:  make(map[worldmap.Alien]bool),
+	}
+	invasion.worldMap.SetRand(rand.New(source))
+
+	// Trapped aliens were already notified before the snapshot
+	for _, alien := range invasion.worldMap.GetTrappedAliens() {
+		invasion.trapped[alien] = true
+	}
+	invasion.worldMap.TakeNewlyTrapped()
+	return invasion, nil
+}
+
+// WriteSnapshot writes the snapshot as JSON
+func WriteSnapshot(w io.Writer, s *Snapshot) error {
+	return json.NewEncoder(w).Encode(s)
+}
+
+// ReadSnapshot reads a snapshot written by WriteSnapshot
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	var s Snapshot
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, inverror.Wrap(inverror.ErrInvalidSnapshot, err.Error())
+	}
+	return &s, nil
+}
@@ -0,0 +1,110 @@
+package invasion_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/harry-hov/alien-invasion/generate"
+	"github.com/harry-hov/alien-invasion/invasion"
+	"github.com/harry-hov/alien-invasion/rng"
+	"github.com/harry-hov/alien-invasion/worldmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkResume checks that an invasion snapshotted after the given moves
+// and resumed ends like the invasion played at once
+func checkResume(t *testing.T, worldMap *worldmap.WorldMap, aliens uint, config invasion.Config, seed int64, moves int) {
+	expected, err := invasion.InitInvasion(worldMap.Clone(), aliens, config, rng.NewSource(seed), nil)
+	require.Nil(t, err)
+	for !expected.IsFinished() {
+		expected.MakeMove()
+		expected.Fight()
+	}
+
+	in, err := invasion.InitInvasion(worldMap, aliens, config, rng.NewSource(seed), nil)
+	require.Nil(t, err)
+	for j := 0; j < moves && !in.IsFinished(); j++ {
+		in.MakeMove()
+		in.Fight()
+	}
+	snapshot, err := in.Snapshot()
+	require.Nil(t, err)
+
+	var buf bytes.Buffer
+	require.Nil(t, invasion.WriteSnapshot(&buf, snapshot))
+	snapshot, err = invasion.ReadSnapshot(&buf)
+	require.Nil(t, err)
+	resumed, err := invasion.Resume(snapshot, nil)
+	require.Nil(t, err)
+	assert.Equal(t, in.GetCurrentMove(), resumed.GetCurrentMove())
+
+	for !resumed.IsFinished() {
+		resumed.MakeMove()
+		resumed.Fight()
+	}
+	assert.Equal(t, expected.Conclusion(), resumed.Conclusion(), seed)
+	assert.Equal(t, expected.GetWorldMap().GetCities(), resumed.GetWorldMap().GetCities(), seed)
+	assert.Equal(t, expected.GetWorldMap().GetAliens(), resumed.GetWorldMap().GetAliens(), seed)
+}
+
+func TestSnapshotResume(t *testing.T) {
+	for seed := int64(0); seed < 40; seed++ {
+		input := worldMapInput
+		if seed%2 == 1 {
+			input = weightedWorldMapInput
+		}
+		config := invasion.DefaultConfig()
+		if seed%4 >= 2 {
+			config.Shards = 3
+		}
+		if seed%3 == 0 {
+			// Headings of aliens following the wall are snapshotted too
+			config.Strategies = []string{"follow-wall", "stay-put:0.5", "seek-nearest:2"}
+		}
+		worldMap, err := worldmap.InitWorldMap(strings.NewReader(input))
+		require.Nil(t, err)
+		checkResume(t, worldMap, 6, config, seed, 3)
+	}
+}
+
+func TestSnapshotResumeAfterFights(t *testing.T) {
+	worldMap, err := generate.Generate(generate.Options{Shape: generate.ShapeGrid, Cities: 100, Seed: 1})
+	require.Nil(t, err)
+	config := invasion.DefaultConfig()
+	config.StopAtSingleSurvivor = false
+	config.MaxMoves = 200
+
+	// Snapshots leave out the aliens killed before them
+	for seed := int64(0); seed < 30; seed++ {
+		in, err := invasion.InitInvasion(worldMap.Clone(), 60, config, rng.NewSource(seed), nil)
+		require.Nil(t, err)
+		for j := 0; j < 5; j++ {
+			in.MakeMove()
+			in.Fight()
+		}
+		require.Less(t, in.GetWorldMap().AlienCount(), 60)
+
+		for _, shards := range []int{0, 4} {
+			config.Shards = shards
+			checkResume(t, worldMap.Clone(), 60, config, seed, 5)
+		}
+	}
+}
+
+func TestSnapshotInvalid(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	require.Nil(t, err)
+	in, err := invasion.InitInvasion(worldMap, 6, invasion.DefaultConfig(), nil, nil)
+	require.Nil(t, err)
+	_, err = in.Snapshot()
+	assert.NotNil(t, err)
+
+	_, err = invasion.Resume(&invasion.Snapshot{Version: 2, World: worldMap}, nil)
+	assert.NotNil(t, err)
+	_, err = invasion.Resume(&invasion.Snapshot{Version: invasion.SnapshotVersion, Config: invasion.DefaultConfig()}, nil)
+	assert.NotNil(t, err)
+	_, err = invasion.ReadSnapshot(strings.NewReader("not json"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,209 @@
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	logerror "github.com/harry-hov/alien-invasion/error"
+	"github.com/harry-hov/alien-invasion/invasion"
+	"github.com/harry-hov/alien-invasion/worldmap"
+)
+
+// Version of the log format
+const Version = 2
+
+const (
+	EventUnleash = "unleash"
+	EventMove    = "move"
+	EventFight   = "fight"
+	EventEnd     = "end"
+)
+
+// Header is the first record of a log
+type Header struct {
+	Version     int             `json:"version"`
+	Seed        int64           `json:"seed"`
+	Config      invasion.Config `json:"config"`
+	Aliens      uint            `json:"aliens"`
+	World       string          `json:"world"`
+	WorldFormat worldmap.Format `json:"world_format"`
+	Directions  string          `json:"directions,omitempty"`
+	WorldHash   string          `json:"world_hash"`
+}
+
+// Event is a single step of an invasion
+//
+// e.g:
+//
+// {"e":"unleash","a":"alien-0","c":"Foo"}
+//
+// {"e":"move","m":1,"a":"alien-0","c":"Bar"}
+//
+// {"e":"fight","m":1,"c":"Bar","as":["alien-0","alien-1"],"ts":["alien-2"]}
+type Event struct {
+	Type       string               `json:"e"`
+	Move       int                  `json:"m,omitempty"`
+	Alien      worldmap.Alien       `json:"a,omitempty"`
+	City       worldmap.City        `json:"c,omitempty"`
+	Aliens     []worldmap.Alien     `json:"as,omitempty"`
+	Travellers []worldmap.Alien     `json:"ts,omitempty"`
+	Conclusion *invasion.Conclusion `json:"r,omitempty"`
+}
+
+// Recorder is an invasion.EventSink writing a log of the invasion
+type Recorder struct {
+	invasion.NopSink
+	encoder *json.Encoder
+	err     error
+}
+
+// NewRecorder writes the header and returns a Recorder writing to w
+func NewRecorder(w io.Writer, header Header) (*Recorder, error) {
+	header.Version = Version
+	r := &Recorder{encoder: json.NewEncoder(w)}
+	if err := r.encoder.Encode(header); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// CheckRecordable returns an error if the aliens already on worldMap
+// cannot be replayed from a log. Logs record where aliens start, not
+// the transit or heading they may start with in JSON and YAML worlds.
+func CheckRecordable(worldMap *worldmap.WorldMap) error {
+	for _, alien := range worldMap.GetAlienList() {
+		if worldMap.GetAlienTransit(alien) > 0 {
+			return logerror.Wrap(logerror.ErrInvalidWorld, fmt.Sprintf("%v starts in transit, which a log cannot record", alien))
+		}
+		if worldMap.GetAlienHeading(alien) != "" {
+			return logerror.Wrap(logerror.ErrInvalidWorld, fmt.Sprintf("%v starts with a heading, which a log cannot record", alien))
+		}
+	}
+	return nil
+}
+
+// Err returns the first error encountered while writing
+func (r *Recorder) Err() error {
+	return r.err
+}
+
+func (r *Recorder) write(e Event) {
+	if r.err == nil {
+		r.err = r.encoder.Encode(e)
+	}
+}
+
+func (r *Recorder) AlienUnleashed(alien worldmap.Alien, city worldmap.City) {
+	r.write(Event{Type: EventUnleash, Alien: alien, City: city})
+}
+
+func (r *Recorder) AlienMoved(move int, m worldmap.Move) {
+	r.write(Event{Type: EventMove, Move: move, Alien: m.Alien, City: m.To})
+}
+
+func (r *Recorder) CityDestroyed(d invasion.Destruction) {
+	r.write(Event{Type: EventFight, Move: d.Move, City: d.City, Aliens: d.Aliens, Travellers: d.Travellers})
+}
+
+func (r *Recorder) InvasionFinished(i *invasion.Invasion) {
+	conclusion := i.Conclusion()
+	r.write(Event{Type: EventEnd, Move: i.GetCurrentMove(), Conclusion: &conclusion})
+}
+
+// Log is a recorded invasion
+type Log struct {
+	Header Header
+	Events []Event
+}
+
+// ReadLog reads a log written by Recorder
+func ReadLog(reader io.Reader) (*Log, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	log := &Log{}
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, logerror.Wrap(logerror.ErrInvalidLog, "empty log")
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &log.Header); err != nil {
+		return nil, logerror.Wrap(logerror.ErrInvalidLog, fmt.Sprintf("line 1: %v", err))
+	}
+	if log.Header.Version != Version {
+		return nil, logerror.Wrap(logerror.ErrInvalidLog, fmt.Sprintf("unsupported version (%v)", log.Header.Version))
+	}
+
+	for line := 2; scanner.Scan(); line++ {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, logerror.Wrap(logerror.ErrInvalidLog, fmt.Sprintf("line %v: %v", line, err))
+		}
+		log.Events = append(log.Events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
+// Replay reconstructs the recorded invasion on worldMap, which must be
+// the world the log was recorded on. It stops after move untilMove,
+// a negative untilMove replays the whole log.
+// Events of the replay are sent to sink.
+func (l *Log) Replay(worldMap *worldmap.WorldMap, untilMove int, sink invasion.EventSink) (*invasion.Invasion, error) {
+	moves := make(map[int][]worldmap.Move)
+	fights := make(map[int][]invasion.Destruction)
+	var end *invasion.Conclusion
+
+	// Aliens are placed as recorded
+	worldMap.KillAliens(worldMap.GetAlienList())
+	for _, e := range l.Events {
+		switch e.Type {
+		case EventUnleash:
+			if err := worldMap.AddAlien(e.Alien, e.City); err != nil {
+				return nil, err
+			}
+		case EventMove:
+			moves[e.Move] = append(moves[e.Move], worldmap.Move{Alien: e.Alien, To: e.City})
+		case EventFight:
+			fights[e.Move] = append(fights[e.Move], invasion.Destruction{Move: e.Move, City: e.City, Aliens: e.Aliens, Travellers: e.Travellers})
+		case EventEnd:
+			end = e.Conclusion
+		default:
+			return nil, logerror.Wrap(logerror.ErrInvalidLog, fmt.Sprintf("unknown event (%v)", e.Type))
+		}
+	}
+
+	in, err := invasion.InitInvasion(worldMap, 0, l.Header.Config, nil, sink)
+	if err != nil {
+		return nil, logerror.Wrap(logerror.ErrInvalidLog, fmt.Sprintf("config: %v", err))
+	}
+	for !in.IsFinished() {
+		if untilMove >= 0 && in.GetCurrentMove() >= untilMove {
+			return in, nil
+		}
+
+		move := in.GetCurrentMove() + 1
+		for i, m := range moves[move] {
+			moves[move][i].From, _ = worldMap.GetAlienCity(m.Alien)
+		}
+		if err := in.ApplyMoves(moves[move]); err != nil {
+			return nil, logerror.Wrap(logerror.ErrInvalidLog, fmt.Sprintf("move %v: %v", move, err))
+		}
+
+		// Fights only depend on alien positions, check them against the log
+		if destructions := in.Fight(); !reflect.DeepEqual(destructions, fights[move]) {
+			return nil, logerror.Wrap(logerror.ErrInvalidLog, fmt.Sprintf("move %v: fights do not match the log", move))
+		}
+	}
+
+	if end != nil && *end != in.Conclusion() {
+		return nil, logerror.Wrap(logerror.ErrInvalidLog, fmt.Sprintf("conclusion (%v) does not match the log (%v)", in.Conclusion(), end))
+	}
+	return in, nil
+}
@@ -0,0 +1,115 @@
+package replay_test
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/harry-hov/alien-invasion/invasion"
+	"github.com/harry-hov/alien-invasion/replay"
+	"github.com/harry-hov/alien-invasion/worldmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const worldMapInput string = `Foo north=Bar west=Baz south=Qu-ux
+Bar south=Foo west=Bee
+`
+
+// weightedWorldMapInput keeps aliens travelling for several moves
+const weightedWorldMapInput string = `Foo north=Bar:3 west=Baz south=Qu-ux:2
+Bar south=Foo:3 west=Bee
+`
+
+func record(t *testing.T, input string, seed int64) (*invasion.Invasion, *bytes.Buffer) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(input))
+	require.Nil(t, err)
+
+	var buf bytes.Buffer
+	config := invasion.DefaultConfig()
+	config.MaxMoves = 50
+	recorder, err := replay.NewRecorder(&buf, replay.Header{Seed: seed, Config: config, Aliens: 6})
+	require.Nil(t, err)
+
+	in, err := invasion.InitInvasion(worldMap, 6, config, rand.NewSource(seed), recorder)
+	require.Nil(t, err)
+	for !in.IsFinished() {
+		in.MakeMove()
+		in.Fight()
+	}
+	require.Nil(t, recorder.Err())
+	return in, &buf
+}
+
+func TestReplay(t *testing.T) {
+	for seed := int64(0); seed < 40; seed++ {
+		input := worldMapInput
+		if seed%2 == 1 {
+			input = weightedWorldMapInput
+		}
+		recorded, buf := record(t, input, seed)
+
+		log, err := replay.ReadLog(buf)
+		require.Nil(t, err)
+		assert.Equal(t, replay.Version, log.Header.Version)
+		assert.Equal(t, seed, log.Header.Seed)
+
+		worldMap, err := worldmap.InitWorldMap(strings.NewReader(input))
+		require.Nil(t, err)
+		replayed, err := log.Replay(worldMap, -1, nil)
+		require.Nil(t, err)
+		assert.Equal(t, recorded.Conclusion(), replayed.Conclusion())
+		assert.Equal(t, recorded.GetWorldMap().GetCities(), replayed.GetWorldMap().GetCities())
+		assert.Equal(t, recorded.GetWorldMap().GetAliens(), replayed.GetWorldMap().GetAliens())
+		for _, alien := range recorded.GetWorldMap().GetAlienList() {
+			assert.Equal(t, recorded.GetWorldMap().GetAlienTransit(alien), replayed.GetWorldMap().GetAlienTransit(alien))
+		}
+	}
+}
+
+func TestReplayUntilMove(t *testing.T) {
+	_, buf := record(t, worldMapInput, 5)
+	log, err := replay.ReadLog(buf)
+	require.Nil(t, err)
+
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	require.Nil(t, err)
+	in, err := log.Replay(worldMap, 0, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 0, in.GetCurrentMove())
+	assert.Equal(t, 6, len(in.GetWorldMap().GetAlienList()))
+}
+
+func TestReplayTampered(t *testing.T) {
+	_, buf := record(t, worldMapInput, 5)
+	log, err := replay.ReadLog(strings.NewReader(strings.Replace(buf.String(), `"e":"fight"`, `"e":"unknown"`, 1)))
+	require.Nil(t, err)
+
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	require.Nil(t, err)
+	_, err = log.Replay(worldMap, -1, nil)
+	assert.NotNil(t, err)
+}
+
+func TestReadLogInvalid(t *testing.T) {
+	_, err := replay.ReadLog(strings.NewReader(""))
+	assert.NotNil(t, err)
+	_, err = replay.ReadLog(strings.NewReader(`{"version":1}`))
+	assert.NotNil(t, err)
+	_, err = replay.ReadLog(strings.NewReader("{\"version\":2}\nnot json"))
+	assert.NotNil(t, err)
+}
+
+func TestCheckRecordable(t *testing.T) {
+	for input, recordable := range map[string]bool{
+		`{"cities":[{"name":"Foo","links":{"north":"Bar"}},{"name":"Bar"}],"aliens":[{"name":"alien-0","city":"Foo"}]}`:                   true,
+		`{"cities":[{"name":"Foo","links":{"north":"Bar:3"}},{"name":"Bar"}],"aliens":[{"name":"alien-0","city":"Bar","transit":2}]}`:     false,
+		`{"cities":[{"name":"Foo","links":{"north":"Bar"}},{"name":"Bar"}],"aliens":[{"name":"alien-0","city":"Foo","heading":"north"}]}`: false,
+	} {
+		worldMap, err := worldmap.Decode(strings.NewReader(input), worldmap.FormatJSON)
+		require.Nil(t, err, input)
+		err = replay.CheckRecordable(worldMap)
+		assert.Equal(t, recordable, err == nil, input)
+	}
+}
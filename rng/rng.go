@@ -0,0 +1,98 @@
+package rng
+
+import "math/rand"
+
+// Parameters of the additive lagged Fibonacci generator of math/rand
+const (
+	length = 607
+	lag    = 273
+	mask   = 1<<63 - 1
+)
+
+// State is the serializable state of a Source
+type State struct {
+	Seed  int64  `json:"seed"`
+	Draws uint64 `json:"draws"`
+	// Vec is the feedback register of the generator after Draws draws,
+	// without it the Source is restored by replaying the draws
+	Vec []uint64 `json:"vec,omitempty"`
+}
+
+// Source is a math/rand Source whose state can be saved and restored.
+// It produces the same numbers as rand.NewSource for the same seed,
+// running the same generator on a register it can save.
+type Source struct {
+	seed      int64
+	draws     uint64
+	vec       [length]uint64
+	tap, feed int
+}
+
+// NewSource returns a Source seeded with seed
+func NewSource(seed int64) *Source {
+	s := &Source{seed: seed, feed: length - lag}
+
+	// Every draw overwrites one value of the register, so after
+	// length draws of math/rand it only holds drawn numbers.
+	src := rand.NewSource(seed).(rand.Source64)
+	for i := 0; i < length; i++ {
+		s.advance()
+		s.vec[s.feed] = src.Uint64()
+	}
+	// Undo the draws, the last one first, to get the seeded register
+	for i := 0; i < length; i++ {
+		s.vec[s.feed] -= s.vec[s.tap]
+		s.tap, s.feed = (s.tap+1)%length, (s.feed+1)%length
+	}
+	return s
+}
+
+// Restore returns a Source in the given state
+// States without a register are restored in as many steps as draws.
+func Restore(state State) *Source {
+	if len(state.Vec) != length {
+		s := NewSource(state.Seed)
+		for s.draws < state.Draws {
+			s.Uint64()
+		}
+		return s
+	}
+	s := &Source{seed: state.Seed, draws: state.Draws}
+	copy(s.vec[:], state.Vec)
+	shift := int(state.Draws % length)
+	s.tap, s.feed = (length-shift)%length, (2*length-lag-shift)%length
+	return s
+}
+
+// State returns the current state of the Source
+func (s *Source) State() State {
+	return State{Seed: s.seed, Draws: s.draws, Vec: append([]uint64(nil), s.vec[:]...)}
+}
+
+// advance moves the taps of the register to the next draw
+func (s *Source) advance() {
+	if s.tap--; s.tap < 0 {
+		s.tap += length
+	}
+	if s.feed--; s.feed < 0 {
+		s.feed += length
+	}
+}
+
+// Int63 implements rand.Source
+func (s *Source) Int63() int64 {
+	return int64(s.Uint64() & mask)
+}
+
+// Uint64 implements rand.Source64
+func (s *Source) Uint64() uint64 {
+	s.draws++
+	s.advance()
+	s.vec[s.feed] += s.vec[s.tap]
+	return s.vec[s.feed]
+}
+
+// Seed implements rand.Source
+func (s *Source) Seed(seed int64) {
+	*s = *NewSource(seed)
+}
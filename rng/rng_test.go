@@ -0,0 +1,68 @@
+package rng_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/harry-hov/alien-invasion/rng"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSource(t *testing.T) {
+	expected := rand.New(rand.NewSource(42))
+	actual := rand.New(rng.NewSource(42))
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, expected.Intn(1000), actual.Intn(1000))
+	}
+
+	// Past the length of the register, with both kinds of draws
+	for _, seed := range []int64{0, -7, 1 << 40} {
+		expected := rand.NewSource(seed).(rand.Source64)
+		actual := rng.NewSource(seed)
+		for i := 0; i < 2000; i++ {
+			if i%3 == 0 {
+				assert.Equal(t, expected.Int63(), actual.Int63())
+			} else {
+				assert.Equal(t, expected.Uint64(), actual.Uint64())
+			}
+		}
+	}
+}
+
+func TestRestore(t *testing.T) {
+	for _, draws := range []int{0, 100, 606, 607, 5000} {
+		src := rng.NewSource(42)
+		r := rand.New(src)
+		for i := 0; i < draws; i++ {
+			r.Int63()
+		}
+
+		state := src.State()
+		assert.Equal(t, int64(42), state.Seed)
+		assert.Equal(t, uint64(draws), state.Draws)
+
+		// States saved without the register are replayed
+		replayed := state
+		replayed.Vec = nil
+		for _, state := range []rng.State{state, replayed} {
+			restored := rng.Restore(state)
+			assert.Equal(t, src.State(), restored.State(), draws)
+			expected, actual := rand.New(rng.Restore(src.State())), rand.New(restored)
+			for i := 0; i < 1000; i++ {
+				assert.Equal(t, expected.Intn(1000), actual.Intn(1000))
+			}
+		}
+	}
+}
+
+func BenchmarkRestore(b *testing.B) {
+	src := rng.NewSource(42)
+	for i := 0; i < 10000000; i++ {
+		src.Uint64()
+	}
+	state := src.State()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rng.Restore(state)
+	}
+}
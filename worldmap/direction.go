@@ -0,0 +1,290 @@
+package worldmap
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+
+	wmerror "github.com/harry-hov/alien-invasion/error"
+)
+
+// DirectionSystem is a set of directions where every
+// direction declares its opposite, e.g north and south.
+// Roads are always added in both directions, so a city
+// can only be linked through directions of its WorldMap system.
+type DirectionSystem struct {
+	name       string
+	directions []Direction
+	opposites  map[Direction]Direction
+	// index is the position of each direction in directions
+	// and opposite the position of its opposite direction
+	index    map[Direction]int
+	opposite []int
+	// offsets place directions on a grid, see WithOffsets
+	offsets map[Direction]Vector
+	// sector is the cosine of the largest angle between the offset
+	// of a direction and a road going in that direction
+	sector float64
+}
+
+// Built-in direction systems
+var (
+	// Compass is the default system: north, east, south and west
+	Compass = mustDirectionSystem("compass", Directions, map[Direction]Direction{
+		North: South,
+		East:  West,
+	}).mustOffsets(map[Direction]Vector{
+		North: {Y: 1},
+		East:  {X: 1},
+	})
+	// Diagonal adds the four diagonals to the compass points
+	Diagonal = mustDirectionSystem("diagonal", []Direction{
+		North, "northeast", East, "southeast", South, "southwest", West, "northwest",
+	}, map[Direction]Direction{
+		North:       South,
+		East:        West,
+		"northeast": "southwest",
+		"southeast": "northwest",
+	}).mustOffsets(map[Direction]Vector{
+		North:       {Y: 1},
+		East:        {X: 1},
+		"northeast": {X: 1, Y: 1},
+		"southeast": {X: 1, Y: -1},
+	})
+	// Vertical adds up and down to the compass points
+	Vertical = mustDirectionSystem("vertical", []Direction{
+		North, East, South, West, "up", "down",
+	}, map[Direction]Direction{
+		North: South,
+		East:  West,
+		"up":  "down",
+	}).mustOffsets(map[Direction]Vector{
+		North: {Y: 1},
+		East:  {X: 1},
+		"up":  {Z: 1},
+	})
+	// Hex links the six neighbours of a (pointy-top) hex grid
+	Hex = mustDirectionSystem("hex", []Direction{
+		"northeast", East, "southeast", "southwest", West, "northwest",
+	}, map[Direction]Direction{
+		East:        West,
+		"northeast": "southwest",
+		"southeast": "northwest",
+	}).mustOffsets(map[Direction]Vector{
+		East:        {X: 1},
+		"northeast": {X: 0.5, Y: math.Sqrt(3) / 2},
+		"southeast": {X: 0.5, Y: -math.Sqrt(3) / 2},
+	})
+)
+
+var directionSystems = struct {
+	sync.RWMutex
+	byName map[string]*DirectionSystem
+}{
+	byName: map[string]*DirectionSystem{
+		Compass.name:  Compass,
+		Diagonal.name: Diagonal,
+		Vertical.name: Vertical,
+		Hex.name:      Hex,
+	},
+}
+
+// NewDirectionSystem returns the direction system iterating over
+// directions in the given order. opposites lists each pair of
+// opposite directions once, e.g {"up": "down"}.
+func NewDirectionSystem(name string, directions []Direction, opposites map[Direction]Direction) (*DirectionSystem, error) {
+	if name == "" {
+		return nil, wmerror.Wrap(wmerror.ErrInvalidDirection, "empty direction system name")
+	}
+	ds := &DirectionSystem{
+		name:       name,
+		directions: append([]Direction(nil), directions...),
+		opposites:  make(map[Direction]Direction, len(directions)),
+	}
+	listed := make(map[Direction]bool, len(directions))
+	for _, d := range directions {
+		if d == "" || string(d) != strings.ToLower(string(d)) || strings.HasPrefix(string(d), "@") ||
+			strings.ContainsAny(string(d), "\"#:=\\") || strings.Contains(string(d), "->") || strings.IndexFunc(string(d), unicode.IsSpace) >= 0 {
+			return nil, invalidDirection(name, d, "must be a lowercase word")
+		}
+		if listed[d] {
+			return nil, invalidDirection(name, d, "is listed twice")
+		}
+		listed[d] = true
+	}
+	for d, opposite := range opposites {
+		if !listed[d] || !listed[opposite] {
+			return nil, invalidDirection(name, d, fmt.Sprintf("and its opposite (%v) must be listed", opposite))
+		}
+		if d == opposite {
+			return nil, invalidDirection(name, d, "cannot be its own opposite")
+		}
+		if _, ok := ds.opposites[d]; ok {
+			return nil, invalidDirection(name, d, "has more than one opposite")
+		}
+		if _, ok := ds.opposites[opposite]; ok {
+			return nil, invalidDirection(name, opposite, "has more than one opposite")
+		}
+		ds.opposites[d] = opposite
+		ds.opposites[opposite] = d
+	}
+	for _, d := range directions {
+		if _, ok := ds.opposites[d]; !ok {
+			return nil, invalidDirection(name, d, "has no opposite")
+		}
+	}
+	ds.index = make(map[Direction]int, len(directions))
+	for i, d := range ds.directions {
+		ds.index[d] = i
+	}
+	for _, d := range ds.directions {
+		ds.opposite = append(ds.opposite, ds.index[ds.opposites[d]])
+	}
+	return ds, nil
+}
+
+func mustDirectionSystem(name string, directions []Direction, opposites map[Direction]Direction) *DirectionSystem {
+	ds, err := NewDirectionSystem(name, directions, opposites)
+	if err != nil {
+		panic(err)
+	}
+	return ds
+}
+
+// WithOffsets returns a copy of the direction system placing each
+// direction on a grid, e.g north at {Y: 1}. offsets lists one direction
+// of each pair of opposite directions, the other is at the opposite offset.
+// Offsets are needed to check the geography of a WorldMap.
+func (ds *DirectionSystem) WithOffsets(offsets map[Direction]Vector) (*DirectionSystem, error) {
+	withOffsets := &DirectionSystem{
+		name:       ds.name,
+		directions: ds.directions,
+		opposites:  ds.opposites,
+		index:      ds.index,
+		opposite:   ds.opposite,
+		offsets:    make(map[Direction]Vector, len(ds.directions)),
+	}
+	for d, offset := range offsets {
+		if !ds.IsValid(d) {
+			return nil, invalidDirection(ds.name, d, "is not in the system")
+		}
+		if offset.length() == 0 {
+			return nil, invalidDirection(ds.name, d, "cannot have a zero offset")
+		}
+		opposite := ds.opposites[d]
+		if _, ok := withOffsets.offsets[opposite]; ok {
+			return nil, invalidDirection(ds.name, d, "and its opposite cannot both have an offset")
+		}
+		withOffsets.offsets[d] = offset
+		withOffsets.offsets[opposite] = offset.scale(-1)
+	}
+	for _, d := range ds.directions {
+		if _, ok := withOffsets.offsets[d]; !ok {
+			return nil, invalidDirection(ds.name, d, "has no offset")
+		}
+	}
+
+	// A road belongs to the closest direction, up to half
+	// of the smallest angle between two directions
+	withOffsets.sector = -1
+	for i, d := range ds.directions {
+		for _, other := range ds.directions[i+1:] {
+			if cos := withOffsets.offsets[d].cos(withOffsets.offsets[other]); cos > 1-epsilon {
+				return nil, invalidDirection(ds.name, other, fmt.Sprintf("has the same offset as (%v)", d))
+			} else if cos > withOffsets.sector {
+				withOffsets.sector = cos
+			}
+		}
+	}
+	withOffsets.sector = math.Cos(math.Acos(withOffsets.sector) / 2)
+	return withOffsets, nil
+}
+
+func (ds *DirectionSystem) mustOffsets(offsets map[Direction]Vector) *DirectionSystem {
+	withOffsets, err := ds.WithOffsets(offsets)
+	if err != nil {
+		panic(err)
+	}
+	return withOffsets
+}
+
+// Offset returns the offset of the direction on the grid
+// It is false if the direction system has no offsets.
+func (ds *DirectionSystem) Offset(d Direction) (Vector, bool) {
+	offset, ok := ds.offsets[d]
+	return offset, ok
+}
+
+func invalidDirection(system string, d Direction, description string) error {
+	return &wmerror.MapError{
+		Err:         wmerror.ErrInvalidDirection,
+		Description: fmt.Sprintf("direction (%v) of system (%v) %v", d, system, description),
+		Direction:   string(d),
+	}
+}
+
+// RegisterDirectionSystem makes the direction system available
+// by name to LookupDirectionSystem, e.g to decode world documents
+func RegisterDirectionSystem(ds *DirectionSystem) error {
+	directionSystems.Lock()
+	defer directionSystems.Unlock()
+	if _, ok := directionSystems.byName[ds.name]; ok {
+		return wmerror.Wrap(wmerror.ErrInvalidDirection, fmt.Sprintf("direction system (%v) already registered", ds.name))
+	}
+	directionSystems.byName[ds.name] = ds
+	return nil
+}
+
+// LookupDirectionSystem returns the registered direction system
+func LookupDirectionSystem(name string) (*DirectionSystem, error) {
+	directionSystems.RLock()
+	defer directionSystems.RUnlock()
+	if ds, ok := directionSystems.byName[name]; ok {
+		return ds, nil
+	}
+	return nil, wmerror.Wrap(wmerror.ErrInvalidDirection, fmt.Sprintf("unknown direction system (%v)", name))
+}
+
+// isRegisteredDirection checks if direction belongs to
+// any registered direction system
+func isRegisteredDirection(d Direction) bool {
+	directionSystems.RLock()
+	defer directionSystems.RUnlock()
+	for _, ds := range directionSystems.byName {
+		if ds.IsValid(d) {
+			return true
+		}
+	}
+	return false
+}
+
+// Name returns the name of the direction system
+func (ds *DirectionSystem) Name() string {
+	return ds.name
+}
+
+// Directions returns the directions in iteration order
+func (ds *DirectionSystem) Directions() []Direction {
+	return append([]Direction(nil), ds.directions...)
+}
+
+// IsValid checks if direction belongs to the system
+func (ds *DirectionSystem) IsValid(d Direction) bool {
+	_, ok := ds.opposites[d]
+	return ok
+}
+
+// Opposite returns the opposite direction
+// e.g Vertical.Opposite("up") == "down"
+func (ds *DirectionSystem) Opposite(d Direction) (Direction, error) {
+	if opposite, ok := ds.opposites[d]; ok {
+		return opposite, nil
+	}
+	return Direction(""), &wmerror.MapError{
+		Err:         wmerror.ErrInvalidDirection,
+		Description: fmt.Sprintf("(%v)", d),
+		Direction:   string(d),
+	}
+}
@@ -0,0 +1,113 @@
+package worldmap_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	wmerror "github.com/harry-hov/alien-invasion/error"
+	"github.com/harry-hov/alien-invasion/worldmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectionSystems(t *testing.T) {
+	assert.Equal(t, worldmap.Directions, worldmap.Compass.Directions())
+	assert.False(t, worldmap.Compass.IsValid("up"))
+	assert.True(t, worldmap.Vertical.IsValid("up"))
+	assert.True(t, worldmap.Hex.IsValid("northeast"))
+	assert.False(t, worldmap.Hex.IsValid(worldmap.North))
+
+	opposite, err := worldmap.Diagonal.Opposite("northwest")
+	assert.Nil(t, err)
+	assert.Equal(t, worldmap.Direction("southeast"), opposite)
+	opposite, err = worldmap.Vertical.Opposite("down")
+	assert.Nil(t, err)
+	assert.Equal(t, worldmap.Direction("up"), opposite)
+	_, err = worldmap.Compass.Opposite("up")
+	assert.True(t, errors.Is(err, wmerror.ErrInvalidDirection))
+
+	ds, err := worldmap.LookupDirectionSystem("hex")
+	assert.Nil(t, err)
+	assert.Equal(t, worldmap.Hex, ds)
+	_, err = worldmap.LookupDirectionSystem("polar")
+	assert.True(t, errors.Is(err, wmerror.ErrInvalidDirection))
+}
+
+func TestNewDirectionSystem(t *testing.T) {
+	ds, err := worldmap.NewDirectionSystem("portals", []worldmap.Direction{"in", "out"}, map[worldmap.Direction]worldmap.Direction{"in": "out"})
+	require.Nil(t, err)
+	assert.Equal(t, "portals", ds.Name())
+	assert.Equal(t, []worldmap.Direction{"in", "out"}, ds.Directions())
+
+	for _, c := range []struct {
+		directions []worldmap.Direction
+		opposites  map[worldmap.Direction]worldmap.Direction
+	}{
+		{[]worldmap.Direction{"in", "out", "side"}, map[worldmap.Direction]worldmap.Direction{"in": "out"}},
+		{[]worldmap.Direction{"in"}, map[worldmap.Direction]worldmap.Direction{"in": "out"}},
+		{[]worldmap.Direction{"in", "out"}, map[worldmap.Direction]worldmap.Direction{"in": "in"}},
+		{[]worldmap.Direction{"In", "out"}, map[worldmap.Direction]worldmap.Direction{"In": "out"}},
+		{[]worldmap.Direction{"in", "out", "in"}, map[worldmap.Direction]worldmap.Direction{"in": "out"}},
+		{[]worldmap.Direction{"a", "b", "c"}, map[worldmap.Direction]worldmap.Direction{"a": "b", "c": "b"}},
+	} {
+		_, err := worldmap.NewDirectionSystem("invalid", c.directions, c.opposites)
+		assert.True(t, errors.Is(err, wmerror.ErrInvalidDirection), c.directions)
+	}
+
+	assert.Nil(t, worldmap.RegisterDirectionSystem(ds))
+	assert.NotNil(t, worldmap.RegisterDirectionSystem(ds))
+}
+
+func TestParseDirectionSystem(t *testing.T) {
+	const input string = `Foo up=Bar northeast=Baz
+Bar north=Bee`
+	_, err := worldmap.Parse(strings.NewReader(input), worldmap.ParseOptions{})
+	assert.True(t, errors.Is(err, wmerror.ErrInvalidDirection))
+
+	_, err = worldmap.Parse(strings.NewReader(input), worldmap.ParseOptions{Directions: worldmap.Vertical})
+	assert.True(t, errors.Is(err, wmerror.ErrInvalidDirection))
+
+	ds, err := worldmap.NewDirectionSystem("mixed",
+		[]worldmap.Direction{worldmap.North, worldmap.South, "northeast", "southwest", "up", "down"},
+		map[worldmap.Direction]worldmap.Direction{worldmap.North: worldmap.South, "northeast": "southwest", "up": "down"})
+	require.Nil(t, err)
+	worldMap, err := worldmap.Parse(strings.NewReader(input), worldmap.ParseOptions{Directions: ds})
+	require.Nil(t, err)
+	assert.Equal(t, ds, worldMap.GetDirectionSystem())
+	assert.Equal(t, []worldmap.Direction{worldmap.North, "down"}, worldMap.GetCityDirections("Bar"))
+	assert.Equal(t, []worldmap.City{"Foo"}, worldMap.GetConnectedCities("Baz"))
+
+	// Roads are destroyed in both directions
+	worldMap.DestroyCity("Foo")
+	assert.Equal(t, []worldmap.Direction{worldmap.North}, worldMap.GetCityDirections("Bar"))
+	assert.Nil(t, worldMap.GetCityDirections("Baz"))
+}
+
+func TestEncodeDirectionSystem(t *testing.T) {
+	worldMap := worldmap.NewWithDirections(worldmap.Hex)
+	worldMap.AddCity("Foo")
+	assert.Nil(t, worldMap.AppendCityDirection("Foo", "Bar", "southeast"))
+	assert.NotNil(t, worldMap.AppendCityDirection("Foo", "Baz", worldmap.South))
+	assert.Equal(t, []worldmap.City{"Foo", "Bar"}, worldMap.GetCities())
+
+	// Structured documents name their direction system
+	var buf bytes.Buffer
+	require.Nil(t, worldMap.Encode(&buf, worldmap.FormatJSON))
+	assert.Contains(t, buf.String(), `"directions": "hex"`)
+	decoded, err := worldmap.Decode(bytes.NewReader(buf.Bytes()), worldmap.FormatJSON)
+	require.Nil(t, err)
+	assert.Equal(t, worldmap.Hex, decoded.GetDirectionSystem())
+	assert.Equal(t, []worldmap.Direction{"northwest"}, decoded.GetCityDirections("Bar"))
+	_, err = worldmap.DecodeWithDirections(bytes.NewReader(buf.Bytes()), worldmap.FormatJSON, worldmap.Compass)
+	assert.True(t, errors.Is(err, wmerror.ErrInvalidDirection))
+
+	// Text files rely on the given direction system
+	buf.Reset()
+	require.Nil(t, worldMap.Encode(&buf, worldmap.FormatText))
+	decoded, err = worldmap.DecodeWithDirections(&buf, worldmap.FormatText, worldmap.Hex)
+	require.Nil(t, err)
+	assert.Equal(t, []worldmap.City{"Foo", "Bar"}, decoded.GetCities())
+	assert.Equal(t, worldmap.Hex, decoded.Clone().GetDirectionSystem())
+}
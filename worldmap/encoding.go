@@ -0,0 +1,412 @@
+package worldmap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	wmerror "github.com/harry-hov/alien-invasion/error"
+	"gopkg.in/yaml.v3"
+)
+
+type Format string
+
+const (
+	FormatText = Format("text")
+	FormatJSON = Format("json")
+	FormatYAML = Format("yaml")
+)
+
+// IsValid checks if format is valid
+func (f Format) IsValid() bool {
+	return f == FormatText || f == FormatJSON || f == FormatYAML
+}
+
+// FormatFromFilename guesses the format from the file extension
+// Files without a known extension are treated as text
+func FormatFromFilename(filename string) Format {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+	return FormatText
+}
+
+// worldMapDocument is the structured (JSON/YAML) representation of WorldMap
+// Directions names a registered direction system, Compass if empty
+type worldMapDocument struct {
+	Directions string          `json:"directions,omitempty" yaml:"directions,omitempty"`
+	Cities     []cityDocument  `json:"cities" yaml:"cities"`
+	Aliens     []alienDocument `json:"aliens,omitempty" yaml:"aliens,omitempty"`
+}
+
+// Links are two-way roads, OneWay one-way roads leaving the city
+// and Costs the number of moves of roads longer than one move
+type cityDocument struct {
+	Name        City               `json:"name" yaml:"name"`
+	Coordinates *Vector            `json:"coordinates,omitempty" yaml:"coordinates,omitempty"`
+	Links       map[Direction]City `json:"links,omitempty" yaml:"links,omitempty"`
+	OneWay      map[Direction]City `json:"one_way,omitempty" yaml:"one_way,omitempty"`
+	Costs       map[Direction]int  `json:"costs,omitempty" yaml:"costs,omitempty"`
+}
+
+// Transit is the number of moves left for an alien travelling to the city,
+// Heading the direction it last took and Strategy its move strategy
+type alienDocument struct {
+	Name     Alien     `json:"name" yaml:"name"`
+	City     City      `json:"city" yaml:"city"`
+	Transit  int       `json:"transit,omitempty" yaml:"transit,omitempty"`
+	Heading  Direction `json:"heading,omitempty" yaml:"heading,omitempty"`
+	Strategy string    `json:"strategy,omitempty" yaml:"strategy,omitempty"`
+}
+
+// Decode returns WorldMap from io.Reader in the given format
+// Like in JSON and YAML, isolated cities are allowed in text format.
+func Decode(reader io.Reader, format Format) (*WorldMap, error) {
+	return DecodeWithDirections(reader, format, nil)
+}
+
+// DecodeWithDirections returns WorldMap from io.Reader in the given format
+// using the direction system ds, Compass if nil. JSON and YAML documents
+// naming their direction system must name ds, if given.
+func DecodeWithDirections(reader io.Reader, format Format, ds *DirectionSystem) (*WorldMap, error) {
+	switch format {
+	case FormatText:
+		return Parse(reader, ParseOptions{AllowIsolatedCities: true, Directions: ds})
+	case FormatJSON:
+		return decodeJSON(reader, ds)
+	case FormatYAML:
+		return decodeYAML(reader, ds)
+	}
+	return nil, wmerror.Wrap(wmerror.ErrInvalidFormat, fmt.Sprintf("(%v)", format))
+}
+
+// Encode writes WorldMap to io.Writer in the given format
+func (wm *WorldMap) Encode(w io.Writer, format Format) error {
+	switch format {
+	case FormatText:
+		_, err := wm.WriteTo(w)
+		return err
+	case FormatJSON:
+		return wm.EncodeJSON(w)
+	case FormatYAML:
+		return wm.EncodeYAML(w)
+	}
+	return wmerror.Wrap(wmerror.ErrInvalidFormat, fmt.Sprintf("(%v)", format))
+}
+
+// DecodeJSON returns WorldMap from JSON document
+func DecodeJSON(reader io.Reader) (*WorldMap, error) {
+	return decodeJSON(reader, nil)
+}
+
+func decodeJSON(reader io.Reader, ds *DirectionSystem) (*WorldMap, error) {
+	var doc worldMapDocument
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, wmerror.Wrap(wmerror.ErrInvalidFormat, err.Error())
+	}
+	return doc.worldMap(ds)
+}
+
+// DecodeYAML returns WorldMap from YAML document
+func DecodeYAML(reader io.Reader) (*WorldMap, error) {
+	return decodeYAML(reader, nil)
+}
+
+func decodeYAML(reader io.Reader, ds *DirectionSystem) (*WorldMap, error) {
+	var doc worldMapDocument
+	decoder := yaml.NewDecoder(reader)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&doc); err != nil && err != io.EOF {
+		return nil, wmerror.Wrap(wmerror.ErrInvalidFormat, err.Error())
+	}
+	return doc.worldMap(ds)
+}
+
+// EncodeJSON writes WorldMap as JSON document
+// Cities and aliens are encoded one at a time and the output is
+// buffered, so encoding a large world does not build it in memory.
+func (wm *WorldMap) EncodeJSON(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	buf.WriteString("{\n")
+	if wm.directions != Compass {
+		name, err := json.Marshal(wm.directions.Name())
+		if err != nil {
+			return err
+		}
+		buf.WriteString(`  "directions": `)
+		buf.Write(name)
+		buf.WriteString(",\n")
+	}
+	buf.WriteString(`  "cities": [`)
+	n := 0
+	for id := range wm.cityNames {
+		if wm.destroyed[id] {
+			continue
+		}
+		if err := writeJSONElement(buf, wm.cityDocument(cityID(id)), n); err != nil {
+			return err
+		}
+		n++
+	}
+	if n > 0 {
+		buf.WriteString("\n  ")
+	}
+	buf.WriteString("]")
+	if wm.alienCount > 0 {
+		buf.WriteString(",\n  \"aliens\": [")
+		n = 0
+		for id, city := range wm.alienCities {
+			if city == noCity {
+				continue
+			}
+			if err := writeJSONElement(buf, wm.alienDocument(alienID(id)), n); err != nil {
+				return err
+			}
+			n++
+		}
+		buf.WriteString("\n  ]")
+	}
+	buf.WriteString("\n}\n")
+	return buf.Flush()
+}
+
+// writeJSONElement writes the n-th element of an array
+// indented as by json.Encoder.SetIndent("", "  ")
+func writeJSONElement(buf *bufio.Writer, v interface{}, n int) error {
+	data, err := json.MarshalIndent(v, "    ", "  ")
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		buf.WriteByte(',')
+	}
+	buf.WriteString("\n    ")
+	_, err = buf.Write(data)
+	return err
+}
+
+// EncodeYAML writes WorldMap as YAML document
+func (wm *WorldMap) EncodeYAML(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(wm.document()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// MarshalJSON implements json.Marshaler
+func (wm *WorldMap) MarshalJSON() ([]byte, error) {
+	return json.Marshal(wm.document())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+// It keeps the random number generator of the WorldMap.
+func (wm *WorldMap) UnmarshalJSON(data []byte) error {
+	var doc worldMapDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	worldMap, err := doc.worldMap(nil)
+	if err != nil {
+		return err
+	}
+	if wm.rand != nil {
+		worldMap.rand = wm.rand
+	}
+	*wm = *worldMap
+	return nil
+}
+
+// document converts WorldMap into its structured representation
+func (wm *WorldMap) document() worldMapDocument {
+	doc := worldMapDocument{Cities: make([]cityDocument, 0, wm.cityCount)}
+	if wm.directions != Compass {
+		doc.Directions = wm.directions.Name()
+	}
+	for id := range wm.cityNames {
+		if !wm.destroyed[id] {
+			doc.Cities = append(doc.Cities, wm.cityDocument(cityID(id)))
+		}
+	}
+	for id, city := range wm.alienCities {
+		if city != noCity {
+			doc.Aliens = append(doc.Aliens, wm.alienDocument(alienID(id)))
+		}
+	}
+	return doc
+}
+
+// cityDocument converts the city into its structured representation
+func (wm *WorldMap) cityDocument(id cityID) cityDocument {
+	entry := cityDocument{Name: wm.cityNames[id]}
+	if coordinates, ok := wm.coordinates[id]; ok {
+		entry.Coordinates = &coordinates
+	}
+	for d, r := range wm.cityRoads(id) {
+		if !r.open() {
+			continue
+		}
+		direction := wm.directions.directions[d]
+		links := &entry.Links
+		if wm.road(r.to, wm.directions.opposite[d]).blocked {
+			links = &entry.OneWay
+		}
+		if *links == nil {
+			*links = make(map[Direction]City)
+		}
+		(*links)[direction] = wm.cityNames[r.to]
+		if r.cost != 1 {
+			if entry.Costs == nil {
+				entry.Costs = make(map[Direction]int)
+			}
+			entry.Costs[direction] = int(r.cost)
+		}
+	}
+	return entry
+}
+
+// alienDocument converts the alien into its structured representation
+func (wm *WorldMap) alienDocument(id alienID) alienDocument {
+	doc := alienDocument{Name: wm.alienNames[id], City: wm.cityNames[wm.alienCities[id]], Transit: int(wm.transit[id])}
+	if heading := wm.headings[id]; heading >= 0 {
+		doc.Heading = wm.directions.directions[heading]
+	}
+	if strategy := wm.strategies[id]; strategy != nil {
+		doc.Strategy = strategy.String()
+	}
+	return doc
+}
+
+// worldMap builds WorldMap from its structured representation
+// applying the same validation rules as the text format.
+// The document uses ds, if it does not name its direction system.
+func (doc worldMapDocument) worldMap(ds *DirectionSystem) (*WorldMap, error) {
+	if doc.Directions != "" {
+		named, err := LookupDirectionSystem(doc.Directions)
+		if err != nil {
+			return nil, err
+		}
+		if ds != nil && ds != named {
+			return nil, wmerror.Wrap(wmerror.ErrInvalidDirection, fmt.Sprintf("world uses direction system (%v), not (%v)", named.Name(), ds.Name()))
+		}
+		ds = named
+	}
+	if ds == nil {
+		ds = Compass
+	}
+	worldMap := NewWithDirections(ds)
+
+	// Add listed cities first to keep them in document order
+	for _, entry := range doc.Cities {
+		if entry.Name == "" {
+			return nil, &wmerror.MapError{Err: wmerror.ErrInvalidCity, Description: "empty city name"}
+		}
+		id := worldMap.addCity(entry.Name)
+		if entry.Coordinates != nil {
+			worldMap.coordinates[id] = *entry.Coordinates
+		}
+	}
+
+	for _, entry := range doc.Cities {
+		for _, roads := range []struct {
+			links  map[Direction]City
+			oneWay bool
+		}{{entry.Links, false}, {entry.OneWay, true}} {
+			if err := worldMap.appendLinks(entry.Name, roads.links, entry.Costs, roads.oneWay); err != nil {
+				return nil, err
+			}
+		}
+		for direction := range entry.Costs {
+			if r, ok := worldMap.lookup(entry.Name, direction); !ok || r.blocked {
+				return nil, &wmerror.MapError{
+					Err:         wmerror.ErrInvalidDirection,
+					Description: fmt.Sprintf("cost of unknown direction (%v) of (%v)", direction, entry.Name),
+					City:        string(entry.Name),
+					Direction:   string(direction),
+				}
+			}
+		}
+	}
+	for _, entry := range doc.Aliens {
+		if err := worldMap.AddAlien(entry.Name, entry.City); err != nil {
+			return nil, err
+		}
+		if entry.Transit < 0 || entry.Transit > maxCost {
+			return nil, &wmerror.MapError{
+				Err:         wmerror.ErrInvalidAlien,
+				Description: fmt.Sprintf("invalid transit (%v) of alien (%v)", entry.Transit, entry.Name),
+				City:        string(entry.City),
+				Alien:       string(entry.Name),
+			}
+		}
+		id := worldMap.alienIDs[entry.Name]
+		if entry.Heading != "" {
+			heading, ok := worldMap.directions.index[entry.Heading]
+			if !ok {
+				return nil, &wmerror.MapError{
+					Err:         wmerror.ErrInvalidDirection,
+					Description: fmt.Sprintf("invalid heading (%v) of alien (%v)", entry.Heading, entry.Name),
+					Direction:   string(entry.Heading),
+					Alien:       string(entry.Name),
+				}
+			}
+			worldMap.headings[id] = int32(heading)
+		}
+		if entry.Strategy != "" {
+			strategy, err := ParseStrategy(entry.Strategy, worldMap.directions)
+			if err != nil {
+				return nil, err
+			}
+			if err := worldMap.SetAlienStrategy(entry.Name, strategy); err != nil {
+				return nil, err
+			}
+		}
+		if entry.Transit > 0 {
+			worldMap.leave(id)
+			worldMap.depart(id, worldMap.alienCities[id], int32(entry.Transit))
+		}
+	}
+	return worldMap, nil
+}
+
+// appendLinks appends the roads of the city applying them in the order
+// of the direction system, so errors are reproducible
+func (wm *WorldMap) appendLinks(city City, links map[Direction]City, costs map[Direction]int, oneWay bool) error {
+	for direction := range links {
+		if !wm.directions.IsValid(direction) {
+			return &wmerror.MapError{
+				Err:         wmerror.ErrInvalidDirection,
+				Description: fmt.Sprintf("cannot parse direction (%v)", direction),
+				City:        string(city),
+				Direction:   string(direction),
+			}
+		}
+	}
+	for _, direction := range wm.directions.directions {
+		directionCity, ok := links[direction]
+		if !ok {
+			continue
+		}
+		if directionCity == "" {
+			return &wmerror.MapError{
+				Err:         wmerror.ErrInvalidCity,
+				Description: fmt.Sprintf("empty city name in direction (%v) of (%v)", direction, city),
+				City:        string(city),
+				Direction:   string(direction),
+			}
+		}
+		if err := wm.AppendRoad(city, directionCity, direction, Road{OneWay: oneWay, Cost: costs[direction]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package worldmap_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	wmerror "github.com/harry-hov/alien-invasion/error"
+	"github.com/harry-hov/alien-invasion/worldmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	jsonWorldMapInput string = `{
+  "cities": [
+    {"name": "Foo", "links": {"north": "Bar", "west": "Baz", "south": "Qu-ux"}},
+    {"name": "Bar", "links": {"south": "Foo", "west": "Bee"}}
+  ],
+  "aliens": [{"name": "alien-0", "city": "Bee"}]
+}`
+	yamlWorldMapInput string = `cities:
+  - name: Foo
+    links: {north: Bar, west: Baz, south: Qu-ux}
+  - name: Bar
+    links: {south: Foo, west: Bee}
+aliens:
+  - {name: alien-0, city: Bee}
+`
+)
+
+func TestFormatFromFilename(t *testing.T) {
+	assert.Equal(t, worldmap.FormatJSON, worldmap.FormatFromFilename("world.JSON"))
+	assert.Equal(t, worldmap.FormatYAML, worldmap.FormatFromFilename("world.yml"))
+	assert.Equal(t, worldmap.FormatYAML, worldmap.FormatFromFilename("world.yaml"))
+	assert.Equal(t, worldmap.FormatText, worldmap.FormatFromFilename("worlds/world-1"))
+}
+
+func TestDecode(t *testing.T) {
+	for _, tc := range []struct {
+		input  string
+		format worldmap.Format
+	}{
+		{jsonWorldMapInput, worldmap.FormatJSON},
+		{yamlWorldMapInput, worldmap.FormatYAML},
+	} {
+		worldMap, err := worldmap.Decode(strings.NewReader(tc.input), tc.format)
+		require.Nil(t, err, tc.format)
+		assert.Equal(t, []worldmap.City{"Foo", "Bar", "Qu-ux", "Baz", "Bee"}, worldMap.GetCities())
+		assert.Equal(t, []worldmap.City{"Bar", "Qu-ux", "Baz"}, worldMap.GetConnectedCities("Foo"))
+		assert.Equal(t, map[worldmap.Alien]worldmap.City{"alien-0": "Bee"}, worldMap.GetAliens())
+	}
+
+	_, err := worldmap.Decode(strings.NewReader(""), worldmap.Format("xml"))
+	assert.NotNil(t, err)
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, input := range []string{
+		`{"cities": [{"name": "Foo", "links": {"wset": "Bar"}}]}`,
+		`{"cities": [{"name": "Foo", "links": {"north": "Foo"}}]}`,
+		`{"cities": [{"name": "Foo", "links": {"north": "Bar"}}, {"name": "Baz", "links": {"south": "Foo"}}]}`,
+		`{"cities": [{"name": "", "links": {"north": "Bar"}}]}`,
+		`{"cities": [{"name": "Foo"}], "aliens": [{"name": "alien-0", "city": "Bar"}]}`,
+		`{"cities": [{"name": "Foo"}], "unknown": true}`,
+	} {
+		_, err := worldmap.DecodeJSON(strings.NewReader(input))
+		assert.NotNil(t, err, input)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	require.Nil(t, err)
+	require.Nil(t, worldMap.AddAlien("alien-0", "Foo"))
+
+	for _, format := range []worldmap.Format{worldmap.FormatJSON, worldmap.FormatYAML} {
+		var buf bytes.Buffer
+		require.Nil(t, worldMap.Encode(&buf, format))
+		decoded, err := worldmap.Decode(&buf, format)
+		require.Nil(t, err, format)
+		assert.Equal(t, worldMap.GetCities(), decoded.GetCities())
+		for _, city := range worldMap.GetCities() {
+			assert.Equal(t, worldMap.GetConnectedCities(city), decoded.GetConnectedCities(city))
+		}
+		assert.Equal(t, worldMap.GetAliens(), decoded.GetAliens())
+	}
+}
+
+func TestEncodeOneWayRoads(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(`Foo north->Bar west=Baz`))
+	require.Nil(t, err)
+
+	for _, format := range []worldmap.Format{worldmap.FormatText, worldmap.FormatJSON, worldmap.FormatYAML} {
+		var buf bytes.Buffer
+		require.Nil(t, worldMap.Encode(&buf, format))
+		decoded, err := worldmap.Decode(&buf, format)
+		require.Nil(t, err, format)
+		assert.True(t, decoded.IsOneWay("Foo", worldmap.North), format)
+		assert.False(t, decoded.IsOneWay("Foo", worldmap.West), format)
+		assert.Nil(t, decoded.GetConnectedCities("Bar"), format)
+		assert.Equal(t, []worldmap.City{"Foo"}, decoded.GetConnectedCities("Baz"), format)
+	}
+
+	_, err = worldmap.DecodeJSON(strings.NewReader(`{"cities":[{"name":"Foo","links":{"north":"Bar"},"one_way":{"north":"Bar"}}]}`))
+	assert.True(t, errors.Is(err, wmerror.ErrInvalidDirection))
+}
+
+func TestEncodeWeightedRoads(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(`Foo north=Bar:3 west->Baz:2`))
+	require.Nil(t, err)
+	require.Nil(t, worldMap.AddAlien("alien-0", "Foo"))
+	require.Nil(t, worldMap.MoveAlien("alien-0", "Bar"))
+
+	for _, format := range []worldmap.Format{worldmap.FormatJSON, worldmap.FormatYAML} {
+		var buf bytes.Buffer
+		require.Nil(t, worldMap.Encode(&buf, format))
+		decoded, err := worldmap.Decode(&buf, format)
+		require.Nil(t, err, format)
+		assert.Equal(t, 3, decoded.GetRoadCost("Bar", worldmap.South), format)
+		assert.Equal(t, 2, decoded.GetRoadCost("Foo", worldmap.West), format)
+		assert.True(t, decoded.IsOneWay("Foo", worldmap.West), format)
+		assert.Equal(t, 2, decoded.GetAlienTransit("alien-0"), format)
+	}
+
+	for _, input := range []string{
+		`{"cities":[{"name":"Foo","links":{"north":"Bar"},"costs":{"south":3}}]}`,
+		`{"cities":[{"name":"Foo","links":{"north":"Bar"},"costs":{"north":-1}}]}`,
+		`{"cities":[{"name":"Foo"}],"aliens":[{"name":"alien-0","city":"Foo","transit":-1}]}`,
+	} {
+		_, err := worldmap.DecodeJSON(strings.NewReader(input))
+		assert.NotNil(t, err, input)
+	}
+}
@@ -0,0 +1,229 @@
+package worldmap
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	wmerror "github.com/harry-hov/alien-invasion/error"
+)
+
+// epsilon is the tolerance when comparing positions
+const epsilon = 1e-9
+
+// Vector is a position or an offset, Z is the altitude
+type Vector struct {
+	X float64 `json:"x" yaml:"x"`
+	Y float64 `json:"y" yaml:"y"`
+	Z float64 `json:"z,omitempty" yaml:"z,omitempty"`
+}
+
+// String returns the vector as written in a world file
+// e.g "3,4" or "3,4,1"
+func (v Vector) String() string {
+	s := formatFloat(v.X) + "," + formatFloat(v.Y)
+	if v.Z != 0 {
+		s += "," + formatFloat(v.Z)
+	}
+	return s
+}
+
+// ParseVector parses a vector written as "x,y" or "x,y,z"
+func ParseVector(s string) (Vector, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 2 && len(fields) != 3 {
+		return Vector{}, fmt.Errorf("expected x,y or x,y,z, got (%v)", s)
+	}
+	var values [3]float64
+	for i, field := range fields {
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil || math.IsInf(value, 0) || math.IsNaN(value) {
+			return Vector{}, fmt.Errorf("cannot parse number (%v)", field)
+		}
+		values[i] = value
+	}
+	return Vector{X: values[0], Y: values[1], Z: values[2]}, nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func (v Vector) add(o Vector) Vector {
+	return Vector{X: v.X + o.X, Y: v.Y + o.Y, Z: v.Z + o.Z}
+}
+
+func (v Vector) sub(o Vector) Vector {
+	return Vector{X: v.X - o.X, Y: v.Y - o.Y, Z: v.Z - o.Z}
+}
+
+func (v Vector) scale(f float64) Vector {
+	return Vector{X: v.X * f, Y: v.Y * f, Z: v.Z * f}
+}
+
+func (v Vector) length() float64 {
+	return math.Sqrt(v.X*v.X + v.Y*v.Y + v.Z*v.Z)
+}
+
+// cos returns the cosine of the angle between two non-zero vectors
+func (v Vector) cos(o Vector) float64 {
+	return (v.X*o.X + v.Y*o.Y + v.Z*o.Z) / (v.length() * o.length())
+}
+
+// near checks if two positions are the same up to rounding errors
+func (v Vector) near(o Vector) bool {
+	return v.sub(o).length() <= epsilon*math.Max(1, math.Max(v.length(), o.length()))
+}
+
+// SetCityCoordinates places the city at the coordinates
+func (wm *WorldMap) SetCityCoordinates(c City, coordinates Vector) error {
+	id, ok := wm.cityIDs[c]
+	if !ok {
+		return &wmerror.MapError{
+			Err:         wmerror.ErrInvalidCity,
+			Description: fmt.Sprintf("unknown city (%v)", c),
+			City:        string(c),
+		}
+	}
+	wm.coordinates[id] = coordinates
+	return nil
+}
+
+// GetCityCoordinates returns the coordinates of the city, if set
+func (wm *WorldMap) GetCityCoordinates(c City) (Vector, bool) {
+	id, ok := wm.cityIDs[c]
+	if !ok {
+		return Vector{}, false
+	}
+	coordinates, ok := wm.coordinates[id]
+	return coordinates, ok
+}
+
+// InferPositions places the cities on the grid of the direction system,
+// a road of cost N leading N offsets of its direction away.
+// The first city of each group of connected cities is at the origin.
+// Roads contradicting the position of a city already placed
+// (e.g Foo north of Bar north of Baz north of Foo) are returned
+// as a wmerror.ErrorList, along with the positions.
+func (wm *WorldMap) InferPositions() (map[City]Vector, error) {
+	if wm.directions.offsets == nil {
+		return nil, wmerror.Wrap(wmerror.ErrInvalidGeography, fmt.Sprintf("direction system (%v) has no offsets", wm.directions.name))
+	}
+
+	placed := make([]bool, len(wm.cityNames))
+	grid := make([]Vector, len(wm.cityNames))
+	reported := make(map[[2]cityID]bool)
+	var errs wmerror.ErrorList
+	var queue []cityID
+	for root := range wm.cityNames {
+		if wm.destroyed[root] || placed[root] {
+			continue
+		}
+		placed[root] = true
+		queue = append(queue[:0], cityID(root))
+		for len(queue) > 0 {
+			city := queue[0]
+			queue = queue[1:]
+			for d, r := range wm.cityRoads(city) {
+				if r.to == noCity {
+					continue
+				}
+				direction := wm.directions.directions[d]
+				position := grid[city].add(wm.directions.offsets[direction].scale(float64(r.cost)))
+				if !placed[r.to] {
+					placed[r.to] = true
+					grid[r.to] = position
+					queue = append(queue, r.to)
+					continue
+				}
+				if grid[r.to].near(position) || reported[[2]cityID{r.to, city}] {
+					continue
+				}
+				reported[[2]cityID{city, r.to}] = true
+				errs = append(errs, &wmerror.MapError{
+					Err: wmerror.ErrInvalidGeography,
+					Description: fmt.Sprintf("direction (%v) from (%v) puts (%v) at (%v), contradicting (%v)",
+						direction, wm.cityNames[city], wm.cityNames[r.to], position, grid[r.to]),
+					City:            string(wm.cityNames[city]),
+					Direction:       string(direction),
+					ConflictingCity: string(wm.cityNames[r.to]),
+				})
+			}
+		}
+	}
+
+	positions := make(map[City]Vector, wm.cityCount)
+	for id, city := range wm.cityNames {
+		if !wm.destroyed[id] {
+			positions[city] = grid[id]
+		}
+	}
+	if errs != nil {
+		return positions, errs
+	}
+	return positions, nil
+}
+
+// CheckGeography reports every road contradicting the grid positions
+// (see InferPositions) or the coordinates of the cities it links,
+// as a wmerror.ErrorList, or nil if the geography is consistent.
+// A road matches the coordinates if it goes closer to its direction
+// than to any other direction of the direction system.
+func (wm *WorldMap) CheckGeography() error {
+	var errs wmerror.ErrorList
+	if _, err := wm.InferPositions(); err != nil {
+		var contradictions wmerror.ErrorList
+		if !errors.As(err, &contradictions) {
+			return err
+		}
+		errs = append(errs, contradictions...)
+	}
+
+	// Roads are checked from the first of their cities
+	checked := make([]bool, len(wm.cityNames))
+	placed := make(map[Vector]cityID, len(wm.coordinates))
+	for id, city := range wm.cityNames {
+		coordinates, ok := wm.coordinates[cityID(id)]
+		if wm.destroyed[id] || !ok {
+			continue
+		}
+		if other, ok := placed[coordinates]; ok {
+			errs = append(errs, &wmerror.MapError{
+				Err:             wmerror.ErrInvalidGeography,
+				Description:     fmt.Sprintf("cities (%v) and (%v) are both at (%v)", wm.cityNames[other], city, coordinates),
+				City:            string(city),
+				ConflictingCity: string(wm.cityNames[other]),
+			})
+		}
+		placed[coordinates] = cityID(id)
+
+		for d, r := range wm.cityRoads(cityID(id)) {
+			if r.to == noCity {
+				continue
+			}
+			directionCoordinates, ok := wm.coordinates[r.to]
+			if !ok || checked[r.to] {
+				continue
+			}
+			direction := wm.directions.directions[d]
+			road := directionCoordinates.sub(coordinates)
+			if road.length() == 0 || road.cos(wm.directions.offsets[direction]) < wm.directions.sector-epsilon {
+				errs = append(errs, &wmerror.MapError{
+					Err: wmerror.ErrInvalidGeography,
+					Description: fmt.Sprintf("direction (%v) from (%v) at (%v) to (%v) at (%v) does not match their coordinates",
+						direction, city, coordinates, wm.cityNames[r.to], directionCoordinates),
+					City:            string(city),
+					Direction:       string(direction),
+					ConflictingCity: string(wm.cityNames[r.to]),
+				})
+			}
+		}
+		checked[id] = true
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package worldmap_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	wmerror "github.com/harry-hov/alien-invasion/error"
+	"github.com/harry-hov/alien-invasion/worldmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVector(t *testing.T) {
+	v, err := worldmap.ParseVector("3,-4.5")
+	assert.Nil(t, err)
+	assert.Equal(t, worldmap.Vector{X: 3, Y: -4.5}, v)
+	assert.Equal(t, "3,-4.5", v.String())
+	v, err = worldmap.ParseVector("0,0,2")
+	assert.Nil(t, err)
+	assert.Equal(t, "0,0,2", v.String())
+
+	for _, s := range []string{"", "1", "1,2,3,4", "1,x", "1,Inf"} {
+		_, err := worldmap.ParseVector(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestCityCoordinates(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(`Foo @0,0 north=Bar
+Bar @0.5,3`))
+	require.Nil(t, err)
+	coordinates, ok := worldMap.GetCityCoordinates("Bar")
+	assert.True(t, ok)
+	assert.Equal(t, worldmap.Vector{X: 0.5, Y: 3}, coordinates)
+	assert.NotNil(t, worldMap.SetCityCoordinates("Baz", worldmap.Vector{}))
+
+	// Coordinates are written back and kept by every format
+	var buf bytes.Buffer
+	_, err = worldMap.WriteTo(&buf)
+	require.Nil(t, err)
+	assert.Equal(t, "Foo @0,0 north=Bar\nBar @0.5,3 south=Foo\n", buf.String())
+	for _, format := range []worldmap.Format{worldmap.FormatText, worldmap.FormatJSON, worldmap.FormatYAML} {
+		buf.Reset()
+		require.Nil(t, worldMap.Encode(&buf, format))
+		decoded, err := worldmap.Decode(&buf, format)
+		require.Nil(t, err, format)
+		coordinates, ok := decoded.GetCityCoordinates("Bar")
+		assert.True(t, ok, format)
+		assert.Equal(t, worldmap.Vector{X: 0.5, Y: 3}, coordinates, format)
+		assert.Equal(t, coordinates, mustCoordinates(t, decoded.Clone(), "Bar"))
+	}
+
+	worldMap.DestroyCity("Bar")
+	_, ok = worldMap.GetCityCoordinates("Bar")
+	assert.False(t, ok)
+
+	for _, input := range []string{`Foo @1 north=Bar`, `Foo @a,b`, `Foo @1,2 @1,3`} {
+		_, err := worldmap.InitWorldMap(strings.NewReader(input))
+		assert.NotNil(t, err, input)
+	}
+}
+
+func mustCoordinates(t *testing.T, worldMap *worldmap.WorldMap, c worldmap.City) worldmap.Vector {
+	coordinates, ok := worldMap.GetCityCoordinates(c)
+	require.True(t, ok)
+	return coordinates
+}
+
+func TestInferPositions(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(`Foo north=Bar east=Baz:2
+Bar east=Qux:2
+Qux south=Baz
+Quux west=Corge`))
+	require.Nil(t, err)
+	positions, err := worldMap.InferPositions()
+	assert.Nil(t, err)
+	assert.Equal(t, map[worldmap.City]worldmap.Vector{
+		"Foo":   {},
+		"Bar":   {Y: 1},
+		"Baz":   {X: 2},
+		"Qux":   {X: 2, Y: 1},
+		"Quux":  {},
+		"Corge": {X: -1},
+	}, positions)
+	assert.Nil(t, worldMap.CheckGeography())
+
+	// Foo is north of Bar, north of Baz, north of Foo
+	worldMap, err = worldmap.InitWorldMap(strings.NewReader(`Foo north=Bar
+Bar north=Baz
+Baz north=Foo`))
+	require.Nil(t, err)
+	_, err = worldMap.InferPositions()
+	var errs wmerror.ErrorList
+	require.True(t, errors.As(err, &errs))
+	assert.Equal(t, 1, len(errs))
+	assert.True(t, errors.Is(err, wmerror.ErrInvalidGeography))
+	var mapErr *wmerror.MapError
+	require.True(t, errors.As(errs[0], &mapErr))
+	assert.Equal(t, "Bar", mapErr.City)
+	assert.Equal(t, "north", mapErr.Direction)
+	assert.Equal(t, "Baz", mapErr.ConflictingCity)
+
+	// Direction systems need offsets
+	ds, err := worldmap.NewDirectionSystem("portals", []worldmap.Direction{"in", "out"}, map[worldmap.Direction]worldmap.Direction{"in": "out"})
+	require.Nil(t, err)
+	worldMap, err = worldmap.Parse(strings.NewReader(`Foo in=Bar`), worldmap.ParseOptions{Directions: ds})
+	require.Nil(t, err)
+	assert.True(t, errors.Is(worldMap.CheckGeography(), wmerror.ErrInvalidGeography))
+
+	ds, err = ds.WithOffsets(map[worldmap.Direction]worldmap.Vector{"in": {Z: -1}})
+	require.Nil(t, err)
+	offset, ok := ds.Offset("out")
+	assert.True(t, ok)
+	assert.Equal(t, worldmap.Vector{Z: 1}, offset)
+	worldMap, err = worldmap.Parse(strings.NewReader(`Foo in=Bar`), worldmap.ParseOptions{Directions: ds})
+	require.Nil(t, err)
+	assert.Nil(t, worldMap.CheckGeography())
+
+	_, err = ds.WithOffsets(map[worldmap.Direction]worldmap.Vector{"in": {}})
+	assert.NotNil(t, err)
+	_, err = ds.WithOffsets(map[worldmap.Direction]worldmap.Vector{})
+	assert.NotNil(t, err)
+	_, err = ds.WithOffsets(map[worldmap.Direction]worldmap.Vector{"in": {Z: -1}, "out": {Z: 1}})
+	assert.NotNil(t, err)
+}
+
+func TestCheckGeography(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(`Foo @0,0 north=Bar east=Qux
+Bar @0.5,5 east=Baz
+Baz @6,6 south=Qux
+Qux @6,-1`))
+	require.Nil(t, err)
+	assert.Nil(t, worldMap.CheckGeography())
+
+	// Roads must go closer to their direction than to any other
+	require.Nil(t, worldMap.SetCityCoordinates("Bar", worldmap.Vector{X: 4, Y: 3}))
+	err = worldMap.CheckGeography()
+	var errs wmerror.ErrorList
+	require.True(t, errors.As(err, &errs))
+	assert.Equal(t, 2, len(errs))
+	assert.Contains(t, errs[0].Error(), "direction (north) from (Foo)")
+	assert.Contains(t, errs[1].Error(), "direction (east) from (Bar)")
+
+	require.Nil(t, worldMap.SetCityCoordinates("Bar", worldmap.Vector{X: 6, Y: 6}))
+	err = worldMap.CheckGeography()
+	require.True(t, errors.As(err, &errs))
+	assert.Contains(t, err.Error(), "cities (Bar) and (Baz) are both at (6,6)")
+
+	// Diagonals split the compass sectors
+	worldMap, err = worldmap.Parse(strings.NewReader(`Foo @0,0 north=Bar
+Bar @1,1`), worldmap.ParseOptions{Directions: worldmap.Diagonal})
+	require.Nil(t, err)
+	assert.NotNil(t, worldMap.CheckGeography())
+
+	worldMap, err = worldmap.Parse(strings.NewReader(`Foo @0,0 northeast=Bar east=Baz
+Bar @1,2 southeast=Baz
+Baz @2,0`), worldmap.ParseOptions{Directions: worldmap.Hex})
+	require.Nil(t, err)
+	assert.Nil(t, worldMap.CheckGeography())
+}
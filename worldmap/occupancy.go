@@ -0,0 +1,207 @@
+package worldmap
+
+import "sort"
+
+// noAlien ends the lists of aliens of a city
+const noAlien = alienID(-1)
+
+// citySet is a set of cities with constant time insertion and removal
+type citySet struct {
+	ids []cityID
+	// index is the position+1 of each city in ids, 0 if not in the set
+	index []int32
+}
+
+func (s *citySet) grow() {
+	s.index = append(s.index, 0)
+}
+
+func (s *citySet) add(id cityID) {
+	if s.index[id] == 0 {
+		s.ids = append(s.ids, id)
+		s.index[id] = int32(len(s.ids))
+	}
+}
+
+func (s *citySet) remove(id cityID) {
+	i := s.index[id]
+	if i == 0 {
+		return
+	}
+	last := s.ids[len(s.ids)-1]
+	s.ids[i-1] = last
+	s.index[last] = i
+	s.ids = s.ids[:len(s.ids)-1]
+	s.index[id] = 0
+}
+
+func (s citySet) clone() citySet {
+	return citySet{ids: append([]cityID(nil), s.ids...), index: append([]int32(nil), s.index...)}
+}
+
+// sorted returns the cities in the order they were added
+func (s citySet) sorted() []cityID {
+	ids := append([]cityID(nil), s.ids...)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// link adds the alien to the list starting at head
+func (wm *WorldMap) link(head *alienID, id alienID) {
+	wm.prev[id] = noAlien
+	wm.next[id] = *head
+	if *head != noAlien {
+		wm.prev[*head] = id
+	}
+	*head = id
+}
+
+// unlink removes the alien from the list starting at head
+func (wm *WorldMap) unlink(head *alienID, id alienID) {
+	if wm.prev[id] != noAlien {
+		wm.next[wm.prev[id]] = wm.next[id]
+	} else {
+		*head = wm.next[id]
+	}
+	if wm.next[id] != noAlien {
+		wm.prev[wm.next[id]] = wm.prev[id]
+	}
+}
+
+// appendList appends the aliens of the list starting at head
+func (wm *WorldMap) appendList(ids []alienID, head alienID) []alienID {
+	for id := head; id != noAlien; id = wm.next[id] {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// alienNamesOf returns the names of the aliens in the order they were unleashed
+func (wm *WorldMap) alienNamesOf(ids []alienID) (aliens []Alien) {
+	if len(ids) == 0 {
+		return nil
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	aliens = make([]Alien, len(ids))
+	for i, id := range ids {
+		aliens[i] = wm.alienNames[id]
+	}
+	return
+}
+
+// arrive places the alien in the city
+func (wm *WorldMap) arrive(id alienID, city cityID) {
+	wm.alienCities[id] = city
+	wm.link(&wm.residents[city], id)
+	if wm.occupants[city]++; wm.occupants[city] == 2 {
+		wm.crowded.add(city)
+	}
+	if wm.isTrapped(city) {
+		wm.trapped++
+		wm.trappedCities.add(city)
+		wm.newlyTrapped = append(wm.newlyTrapped, id)
+	}
+}
+
+// leave takes the alien out of its city
+func (wm *WorldMap) leave(id alienID) {
+	city := wm.alienCities[id]
+	wm.unlink(&wm.residents[city], id)
+	if wm.occupants[city]--; wm.occupants[city] == 1 {
+		wm.crowded.remove(city)
+	}
+	if wm.isTrapped(city) {
+		wm.trapped--
+		if wm.occupants[city] == 0 {
+			wm.trappedCities.remove(city)
+		}
+	}
+}
+
+// depart sends the alien travelling to the city for the moves
+func (wm *WorldMap) depart(id alienID, city cityID, moves int32) {
+	wm.alienCities[id] = city
+	wm.transit[id] = moves
+	wm.travelling++
+	wm.link(&wm.inbound[city], id)
+}
+
+// land ends the journey of the alien in its city
+func (wm *WorldMap) land(id alienID) {
+	city := wm.alienCities[id]
+	wm.unlink(&wm.inbound[city], id)
+	wm.transit[id] = 0
+	wm.travelling--
+	wm.arrive(id, city)
+}
+
+// updateTrapped counts the aliens of the city as trapped or not
+// once its roads changed
+func (wm *WorldMap) updateTrapped(city cityID, wasTrapped bool) {
+	trapped := wm.isTrapped(city)
+	if trapped == wasTrapped || wm.occupants[city] == 0 {
+		return
+	}
+	if trapped {
+		wm.trapped += int(wm.occupants[city])
+		wm.trappedCities.add(city)
+		wm.newlyTrapped = wm.appendList(wm.newlyTrapped, wm.residents[city])
+	} else {
+		wm.trapped -= int(wm.occupants[city])
+		wm.trappedCities.remove(city)
+	}
+}
+
+// TakeNewlyTrapped returns the aliens trapped since the last call
+// in the order they were unleashed, and forgets them. Aliens killed
+// or with a way out again since they were trapped are left out.
+// It only looks at the aliens trapped in between, not at all trapped aliens.
+func (wm *WorldMap) TakeNewlyTrapped() []Alien {
+	ids := wm.newlyTrapped[:0]
+	seen := make(map[alienID]bool, len(wm.newlyTrapped))
+	for _, id := range wm.newlyTrapped {
+		city := wm.alienCities[id]
+		if !seen[id] && city != noCity && wm.transit[id] == 0 && wm.isTrapped(city) {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	wm.newlyTrapped = wm.newlyTrapped[:0]
+	return wm.alienNamesOf(ids)
+}
+
+// GetCityAliens returns the aliens in the city
+// in the order they were unleashed.
+// Aliens travelling to the city are not in it yet.
+func (wm *WorldMap) GetCityAliens(c City) []Alien {
+	city, ok := wm.cityIDs[c]
+	if !ok {
+		return nil
+	}
+	return wm.alienNamesOf(wm.appendList(nil, wm.residents[city]))
+}
+
+// GetCityAlienCount returns the number of aliens in the city
+// Aliens travelling to the city are not in it yet.
+func (wm *WorldMap) GetCityAlienCount(c City) int {
+	city, ok := wm.cityIDs[c]
+	if !ok {
+		return 0
+	}
+	return int(wm.occupants[city])
+}
+
+// GetCrowdedCities returns the cities with at least n aliens in them
+// in the order they were added. It only looks at cities with
+// two aliens or more, so it does not scan the whole WorldMap.
+func (wm *WorldMap) GetCrowdedCities(n int) (cities []City) {
+	if n < 2 {
+		return wm.GetOccupiedCities()
+	}
+	for _, city := range wm.crowded.sorted() {
+		if !wm.destroyed[city] && int(wm.occupants[city]) >= n {
+			cities = append(cities, wm.cityNames[city])
+		}
+	}
+	return
+}
@@ -0,0 +1,103 @@
+package worldmap_test
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/harry-hov/alien-invasion/worldmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const occupancyWorldMapInput = `Foo north=Bar:3 west=Baz south->Qu-ux
+Bar west=Bee:2 north->Foo2
+Baz north=Bee west=Qu-ux:2
+Qu-ux west->Foo2
+Foo2 north->Bar2
+`
+
+// checkOccupancy compares the bookkeeping of the WorldMap
+// with the occupancy computed from the alien placements
+func checkOccupancy(t *testing.T, worldMap *worldmap.WorldMap) {
+	aliensByCity := map[worldmap.City][]worldmap.Alien{}
+	travelling := map[worldmap.City][]worldmap.Alien{}
+	var trapped []worldmap.Alien
+	for _, alien := range worldMap.GetAlienList() {
+		city, ok := worldMap.GetAlienCity(alien)
+		require.True(t, ok)
+		if worldMap.GetAlienTransit(alien) > 0 {
+			travelling[city] = append(travelling[city], alien)
+			continue
+		}
+		aliensByCity[city] = append(aliensByCity[city], alien)
+		if worldMap.GetCityDirections(city) == nil {
+			trapped = append(trapped, alien)
+		}
+	}
+
+	assert.Equal(t, trapped, worldMap.GetTrappedAliens())
+	assert.Equal(t, uint(len(trapped)), worldMap.GetTrappedAlienCount())
+	assert.Equal(t, len(worldMap.GetAlienList()), worldMap.AlienCount())
+	for n := 1; n <= 3; n++ {
+		var crowded []worldmap.City
+		for _, city := range worldMap.GetCities() {
+			if len(aliensByCity[city]) >= n {
+				crowded = append(crowded, city)
+			}
+		}
+		assert.Equal(t, crowded, worldMap.GetCrowdedCities(n), n)
+	}
+	for _, city := range worldMap.GetCities() {
+		assert.Equal(t, aliensByCity[city], worldMap.GetCityAliens(city), city)
+		assert.Equal(t, travelling[city], worldMap.GetAliensTravellingTo(city), city)
+	}
+}
+
+func TestOccupancy(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		worldMap, err := worldmap.InitWorldMap(strings.NewReader(occupancyWorldMapInput))
+		require.Nil(t, err)
+		r := rand.New(rand.NewSource(seed))
+		worldMap.SetRand(r)
+		worldMap.UnleaseNAliens(12)
+		checkOccupancy(t, worldMap)
+
+		for move := 0; move < 10 && worldMap.CityCount() > 0; move++ {
+			worldMap.RandWalkAlien()
+			checkOccupancy(t, worldMap)
+
+			switch r.Intn(4) {
+			case 0:
+				// As in a fight, aliens die with their city
+				cities := worldMap.GetCities()
+				city := cities[r.Intn(len(cities))]
+				aliens := append(worldMap.GetCityAliens(city), worldMap.GetAliensTravellingTo(city)...)
+				worldMap.DestroyCity(city)
+				checkOccupancy(t, worldMap)
+				worldMap.KillAliens(aliens)
+			case 1:
+				if aliens := worldMap.GetAlienList(); aliens != nil {
+					worldMap.KillAliens(aliens[:1+r.Intn(len(aliens))/2])
+				}
+			case 2:
+				// Roads added later free trapped aliens
+				cities := worldMap.GetCities()
+				from, to := cities[r.Intn(len(cities))], cities[r.Intn(len(cities))]
+				worldMap.AppendRoad(from, to, worldmap.Directions[r.Intn(4)], worldmap.Road{OneWay: r.Intn(2) == 0, Cost: 1 + r.Intn(2)})
+			case 3:
+				worldMap = worldMap.Clone()
+				worldMap.SetRand(r)
+			}
+			checkOccupancy(t, worldMap)
+		}
+
+		// Travelling aliens are restored from documents
+		var buf bytes.Buffer
+		require.Nil(t, worldMap.EncodeJSON(&buf))
+		decoded, err := worldmap.DecodeJSON(&buf)
+		require.Nil(t, err)
+		checkOccupancy(t, decoded)
+	}
+}
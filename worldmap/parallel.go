@@ -0,0 +1,95 @@
+package worldmap
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Choices of the aliens drawn by ParallelRandWalkAlien
+// besides the index of the direction they take
+const (
+	stay    = -1
+	advance = -2
+)
+
+// ParallelRandWalkAlien moves the aliens like RandWalkAlien, drawing
+// their directions concurrently. Live aliens are split into shards of
+// aliens unleashed one after the other, each drawing from its own random
+// number generator seeded by the one of the WorldMap. The moves only depend
+// on the seed, the live aliens and the number of shards, not on the
+// scheduling of the shards nor on the aliens killed before, so a WorldMap
+// restored without them moves the same.
+// The moves are then made in the order the aliens were unleashed.
+func (wm *WorldMap) ParallelRandWalkAlien(shards int) (moves []Move) {
+	if shards < 1 {
+		shards = 1
+	}
+	live := wm.live[:0]
+	for id, city := range wm.alienCities {
+		if city != noCity {
+			live = append(live, alienID(id))
+		}
+	}
+	wm.live = live
+	if cap(wm.choices) < len(live) {
+		wm.choices = make([]int32, len(live))
+	}
+	choices := wm.choices[:len(live)]
+
+	var wg sync.WaitGroup
+	for shard := 0; shard < shards; shard++ {
+		r := rand.New(rand.NewSource(wm.rand.Int63()))
+		from, to := shard*len(live)/shards, (shard+1)*len(live)/shards
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wm.drawChoices(r, live[from:to], choices[from:to])
+		}()
+	}
+	wg.Wait()
+
+	for i, choice := range choices {
+		id := live[i]
+		switch choice {
+		case stay:
+		case advance:
+			wm.advanceTransit(id)
+		default:
+			city := wm.alienCities[id]
+			to := wm.travel(id, city, int(choice))
+			if moves == nil {
+				moves = make([]Move, 0, wm.alienCount)
+			}
+			moves = append(moves, Move{Alien: wm.alienNames[id], From: wm.cityNames[city], To: wm.cityNames[to]})
+		}
+	}
+	return
+}
+
+// drawChoices draws the choices of the aliens
+// It only reads the WorldMap, so shards can draw at the same time.
+func (wm *WorldMap) drawChoices(random *rand.Rand, ids []alienID, choices []int32) {
+	exits := make([]int32, 0, len(wm.directions.directions))
+	for i, id := range ids {
+		city := wm.alienCities[id]
+		if wm.transit[id] > 0 {
+			choices[i] = advance
+			continue
+		}
+		exits = exits[:0]
+		for d, r := range wm.cityRoads(city) {
+			if r.open() {
+				exits = append(exits, int32(d))
+			}
+		}
+		choices[i] = stay
+		if len(exits) == 0 {
+			continue
+		}
+		if wm.strategies[id] == nil {
+			choices[i] = exits[random.Intn(len(exits))]
+		} else if choice := wm.choose(id, exits, random); choice >= 0 {
+			choices[i] = exits[choice]
+		}
+	}
+}
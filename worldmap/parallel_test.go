@@ -0,0 +1,57 @@
+package worldmap_test
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/harry-hov/alien-invasion/worldmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParallelRandWalkAlien(t *testing.T) {
+	walk := func(seed int64, shards int) (moves [][]worldmap.Move, worldMap *worldmap.WorldMap) {
+		worldMap, err := worldmap.InitWorldMap(strings.NewReader(occupancyWorldMapInput))
+		require.Nil(t, err)
+		worldMap.SetRand(rand.New(rand.NewSource(seed)))
+		worldMap.UnleaseNAliens(20)
+		for move := 0; move < 10; move++ {
+			before := worldMap.Clone()
+			moved := worldMap.ParallelRandWalkAlien(shards)
+			moves = append(moves, moved)
+			checkOccupancy(t, worldMap)
+
+			// Replaying the moves gives the same WorldMap
+			before.AdvanceTransit()
+			for _, m := range moved {
+				require.Nil(t, before.MoveAlien(m.Alien, m.To))
+			}
+			assert.Equal(t, worldMap.GetAliens(), before.GetAliens())
+		}
+		return
+	}
+
+	for seed := int64(0); seed < 5; seed++ {
+		for _, shards := range []int{1, 3, 8, 32} {
+			moves, worldMap := walk(seed, shards)
+			again, _ := walk(seed, shards)
+			assert.Equal(t, moves, again, shards)
+			assert.Equal(t, 20, worldMap.AlienCount())
+		}
+	}
+}
+
+func BenchmarkParallelRandWalkAlien(b *testing.B) {
+	worldMap := gridWorldMap(1000)
+	worldMap.SetRand(rand.New(rand.NewSource(1)))
+	worldMap.UnleaseNAliens(100000)
+	for _, shards := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("shards-%v", shards), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				worldMap.ParallelRandWalkAlien(shards)
+			}
+		})
+	}
+}
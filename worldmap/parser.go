@@ -0,0 +1,300 @@
+package worldmap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	wmerror "github.com/harry-hov/alien-invasion/error"
+)
+
+// ParseOptions configures how a world file is parsed
+type ParseOptions struct {
+	// CollectErrors keeps parsing after a problem and returns
+	// every problem found as a wmerror.ErrorList
+	CollectErrors bool
+	// AllowIsolatedCities accepts lines with a city and no directions,
+	// as written for cities whose roads were all destroyed
+	AllowIsolatedCities bool
+	// Directions is the direction system of the world, Compass if nil
+	Directions *DirectionSystem
+}
+
+// token is a word of a line with its 1-based column
+type token struct {
+	// text is the token as written, value without quotes
+	text   string
+	value  string
+	column int
+	// equals, arrows and colons are the positions in value
+	// of =, -> and : outside quotes
+	equals []int
+	arrows []int
+	colons []int
+}
+
+// parser reads a world file line by line
+type parser struct {
+	opts     ParseOptions
+	worldMap *WorldMap
+	errs     wmerror.ErrorList
+	line     int
+}
+
+// Parse returns WorldMap from io.Reader
+//
+// Every problem is a *wmerror.ParseError with line and column.
+// Unless opts.CollectErrors is set, it fails on the first problem.
+func Parse(reader io.Reader, opts ParseOptions) (*WorldMap, error) {
+	if opts.Directions == nil {
+		opts.Directions = Compass
+	}
+	p := &parser{opts: opts, worldMap: NewWithDirections(opts.Directions)}
+	scanner := bufio.NewScanner(reader)
+
+	for scanner.Scan() {
+		p.line++
+		p.parseLine(scanner.Text())
+		if len(p.errs) > 0 && !opts.CollectErrors {
+			return nil, p.errs[0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(p.errs) > 0 {
+		return nil, p.errs
+	}
+	return p.worldMap, nil
+}
+
+// ValidateWorldMap returns every problem of the world file
+// as a wmerror.ErrorList, or nil if it is valid
+func ValidateWorldMap(reader io.Reader, opts ParseOptions) error {
+	opts.CollectErrors = true
+	_, err := Parse(reader, opts)
+	return err
+}
+
+// fail records a problem at the token
+func (p *parser) fail(t token, err error) {
+	p.errs = append(p.errs, &wmerror.ParseError{
+		Line:   p.line,
+		Column: t.column,
+		Token:  t.text,
+		Err:    err,
+	})
+}
+
+func (p *parser) parseLine(line string) {
+	// Tokenize line, skipping blank and comment lines
+	tokens, err := tokenize(line)
+	if err != nil {
+		p.fail(err.at, &wmerror.MapError{Err: wmerror.ErrInvalidCity, Description: err.description})
+		return
+	}
+	if len(tokens) == 0 {
+		return
+	}
+
+	city := City(tokens[0].value)
+	if city == "" {
+		p.fail(tokens[0], &wmerror.MapError{Err: wmerror.ErrInvalidCity, Description: "empty city name"})
+		return
+	}
+	if len(tokens) < 2 && !p.opts.AllowIsolatedCities {
+		p.fail(tokens[0], &wmerror.MapError{
+			Err:         wmerror.ErrInvalidCity,
+			Description: fmt.Sprintf("isolated city (%v)", city),
+			City:        string(city),
+		})
+		return
+	}
+
+	// Add city to WorldMap
+	p.worldMap.AddCity(city)
+
+	for _, t := range tokens[1:] {
+		// @x,y or @x,y,z are the coordinates of the city
+		if strings.HasPrefix(t.text, "@") {
+			p.parseCoordinates(city, t)
+			continue
+		}
+		if len(t.equals)+len(t.arrows) != 1 {
+			p.fail(t, &wmerror.MapError{
+				Err:         wmerror.ErrInvalidDirection,
+				Description: fmt.Sprintf("cannot parse direction entry (%v)", t.text),
+				City:        string(city),
+			})
+			continue
+		}
+		// direction=City is a two-way road, direction->City a one-way road
+		oneWay := len(t.arrows) == 1
+		var separator, end int
+		if oneWay {
+			separator, end = t.arrows[0], t.arrows[0]+len("->")
+		} else {
+			separator, end = t.equals[0], t.equals[0]+len("=")
+		}
+		keyword := t.value[:separator]
+		direction := Direction(strings.ToLower(keyword))
+		if !p.opts.Directions.IsValid(direction) {
+			p.fail(t, &wmerror.MapError{
+				Err:         wmerror.ErrInvalidDirection,
+				Description: fmt.Sprintf("cannot parse direction (%v)", keyword),
+				City:        string(city),
+				Direction:   keyword,
+			})
+			continue
+		}
+		// direction=City:N takes N moves to travel
+		cost, costAt := 1, len(t.value)
+		if n := len(t.colons); n > 0 && t.colons[n-1] >= end {
+			costAt = t.colons[n-1]
+			var err error
+			if cost, err = strconv.Atoi(t.value[costAt+1:]); err != nil || cost < 1 {
+				p.fail(t, &wmerror.MapError{
+					Err:         wmerror.ErrInvalidDirection,
+					Description: fmt.Sprintf("cannot parse cost (%v) of direction (%v) of (%v)", t.value[costAt+1:], direction, city),
+					City:        string(city),
+					Direction:   string(direction),
+				})
+				continue
+			}
+		}
+		directionCity := City(t.value[end:costAt])
+		if directionCity == "" {
+			p.fail(t, &wmerror.MapError{
+				Err:         wmerror.ErrInvalidCity,
+				Description: fmt.Sprintf("empty city name in direction (%v) of (%v)", direction, city),
+				City:        string(city),
+				Direction:   string(direction),
+			})
+			continue
+		}
+		if err := p.worldMap.AppendRoad(city, directionCity, direction, Road{OneWay: oneWay, Cost: cost}); err != nil {
+			p.fail(t, err)
+		}
+	}
+}
+
+func (p *parser) parseCoordinates(city City, t token) {
+	coordinates, err := ParseVector(t.value[1:])
+	if err != nil {
+		p.fail(t, &wmerror.MapError{
+			Err:         wmerror.ErrInvalidCity,
+			Description: fmt.Sprintf("cannot parse coordinates (%v) of (%v): %v", t.text, city, err),
+			City:        string(city),
+		})
+		return
+	}
+	if placed, ok := p.worldMap.GetCityCoordinates(city); ok && placed != coordinates {
+		p.fail(t, &wmerror.MapError{
+			Err:         wmerror.ErrInvalidCity,
+			Description: fmt.Sprintf("city (%v) already at (%v)", city, placed),
+			City:        string(city),
+		})
+		return
+	}
+	if err := p.worldMap.SetCityCoordinates(city, coordinates); err != nil {
+		p.fail(t, err)
+	}
+}
+
+// tokenizeError is a line that cannot be split into tokens
+type tokenizeError struct {
+	at          token
+	description string
+}
+
+// tokenize splits the line into tokens keeping the column of each token
+//
+// Tokens are separated by any whitespace and everything after
+// a # is a comment. Double quotes group words into a single token
+// (e.g "New York"), \" and \\ escape a quote and a backslash.
+func tokenize(line string) (tokens []token, err *tokenizeError) {
+	runes := []rune(line)
+	var current *token
+	var value strings.Builder
+	start, inQuote := 0, false
+
+	flush := func(end int) {
+		if current != nil {
+			current.text = string(runes[start:end])
+			current.value = value.String()
+			tokens = append(tokens, *current)
+			current = nil
+			value.Reset()
+		}
+	}
+
+	i := 0
+	for ; i < len(runes); i++ {
+		r := runes[i]
+		if inQuote {
+			switch {
+			case r == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\'):
+				i++
+				value.WriteRune(runes[i])
+			case r == '"':
+				inQuote = false
+			default:
+				value.WriteRune(r)
+			}
+			continue
+		}
+
+		if unicode.IsSpace(r) {
+			flush(i)
+			continue
+		}
+		if r == '#' {
+			break
+		}
+		if current == nil {
+			current = &token{column: i + 1}
+			start = i
+		}
+		switch r {
+		case '"':
+			inQuote = true
+		case '=':
+			current.equals = append(current.equals, value.Len())
+			value.WriteRune(r)
+		case ':':
+			current.colons = append(current.colons, value.Len())
+			value.WriteRune(r)
+		case '-':
+			if i+1 < len(runes) && runes[i+1] == '>' {
+				current.arrows = append(current.arrows, value.Len())
+				value.WriteString("->")
+				i++
+				continue
+			}
+			value.WriteRune(r)
+		default:
+			value.WriteRune(r)
+		}
+	}
+
+	if inQuote {
+		current.text = string(runes[start:])
+		return nil, &tokenizeError{at: *current, description: fmt.Sprintf("unterminated quote (%v)", current.text)}
+	}
+	flush(i)
+	return
+}
+
+// quote returns the city as written in a world file,
+// quoted if it would not be read back as a single token
+func quote(c City) string {
+	if c != "" && !strings.ContainsAny(string(c), "\"#:=\\") && !strings.Contains(string(c), "->") &&
+		strings.IndexFunc(string(c), unicode.IsSpace) < 0 {
+		return string(c)
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(string(c)) + `"`
+}
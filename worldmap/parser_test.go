@@ -0,0 +1,148 @@
+package worldmap_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	wmerror "github.com/harry-hov/alien-invasion/error"
+	"github.com/harry-hov/alien-invasion/worldmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const invalidWorldMapLines string = `Foo north=Bar wset=Baz
+Bar
+
+Baz south=Foo northQux
+`
+
+func TestParseFirstError(t *testing.T) {
+	_, err := worldmap.Parse(strings.NewReader(invalidWorldMapLines), worldmap.ParseOptions{})
+	var parseErr *wmerror.ParseError
+	require.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, 1, parseErr.Line)
+	assert.Equal(t, 15, parseErr.Column)
+	assert.Equal(t, "wset=Baz", parseErr.Token)
+	assert.True(t, errors.Is(err, wmerror.ErrInvalidDirection))
+}
+
+func TestValidateWorldMap(t *testing.T) {
+	assert.Nil(t, worldmap.ValidateWorldMap(strings.NewReader(worldMapInput), worldmap.ParseOptions{}))
+
+	err := worldmap.ValidateWorldMap(strings.NewReader(invalidWorldMapLines), worldmap.ParseOptions{})
+	var problems wmerror.ErrorList
+	require.True(t, errors.As(err, &problems))
+	require.Equal(t, 4, len(problems))
+
+	expected := []struct {
+		line, column int
+		token        string
+		sentinel     error
+	}{
+		{1, 15, "wset=Baz", wmerror.ErrInvalidDirection},
+		{2, 1, "Bar", wmerror.ErrInvalidCity},
+		{4, 5, "south=Foo", wmerror.ErrInvalidDirection},
+		{4, 15, "northQux", wmerror.ErrInvalidDirection},
+	}
+	for i, e := range expected {
+		var parseErr *wmerror.ParseError
+		require.True(t, errors.As(problems[i], &parseErr))
+		assert.Equal(t, e.line, parseErr.Line)
+		assert.Equal(t, e.column, parseErr.Column)
+		assert.Equal(t, e.token, parseErr.Token)
+		assert.True(t, errors.Is(parseErr, e.sentinel))
+	}
+	assert.True(t, errors.Is(err, wmerror.ErrInvalidCity))
+
+	// Problems carry the city and direction
+	var mapErr *wmerror.MapError
+	require.True(t, errors.As(problems[2], &mapErr))
+	assert.Equal(t, "Foo", mapErr.City)
+	assert.Equal(t, "north", mapErr.Direction)
+	assert.Equal(t, "Bar", mapErr.ConflictingCity)
+}
+
+func TestParseTolerant(t *testing.T) {
+	const input string = `# Sample world
+  Foo	 north=Bar   West=Baz  # trailing comment
+"New York" north="Jersey City" west=Foo
+"Jersey \"JC\" City" north=Baz
+
+Bar  SOUTH=Foo
+`
+	worldMap, err := worldmap.Parse(strings.NewReader(input), worldmap.ParseOptions{})
+	require.Nil(t, err)
+	assert.Equal(t, []worldmap.City{"Foo", "Bar", "Baz", "New York", "Jersey City", `Jersey "JC" City`}, worldMap.GetCities())
+	assert.Equal(t, []worldmap.City{"Bar", "New York", "Baz"}, worldMap.GetConnectedCities("Foo"))
+	assert.Equal(t, []worldmap.City{"Jersey City", "Foo"}, worldMap.GetConnectedCities("New York"))
+
+	// Quoted names are written back quoted
+	var buf bytes.Buffer
+	_, err = worldMap.WriteTo(&buf)
+	require.Nil(t, err)
+	assert.Contains(t, buf.String(), `"New York" north="Jersey City" west=Foo`)
+	assert.Contains(t, buf.String(), `"Jersey \"JC\" City" north=Baz`)
+	reread, err := worldmap.InitWorldMap(&buf)
+	require.Nil(t, err)
+	assert.ElementsMatch(t, worldMap.GetCities(), reread.GetCities())
+}
+
+func TestParseTolerantInvalid(t *testing.T) {
+	for _, input := range []string{
+		`"New York north=Bar`,
+		`Foo north=`,
+		`"" north=Bar`,
+		`Foo north=Bar=Baz`,
+	} {
+		_, err := worldmap.InitWorldMap(strings.NewReader(input))
+		assert.NotNil(t, err, input)
+	}
+
+	_, err := worldmap.InitWorldMap(strings.NewReader(`Foo north=Bar "New York`))
+	var parseErr *wmerror.ParseError
+	require.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, 15, parseErr.Column)
+}
+
+func TestParseIsolatedCities(t *testing.T) {
+	const input string = `Foo north=Bar
+Baz
+Bar
+`
+	_, err := worldmap.Parse(strings.NewReader(input), worldmap.ParseOptions{})
+	assert.True(t, errors.Is(err, wmerror.ErrInvalidCity))
+
+	worldMap, err := worldmap.Parse(strings.NewReader(input), worldmap.ParseOptions{AllowIsolatedCities: true})
+	require.Nil(t, err)
+	assert.Equal(t, []worldmap.City{"Foo", "Bar", "Baz"}, worldMap.GetCities())
+	assert.Nil(t, worldMap.GetConnectedCities("Baz"))
+
+	// Decode allows isolated cities, so a remaining world can be read back
+	worldMap.DestroyCity("Foo")
+	var buf bytes.Buffer
+	require.Nil(t, worldMap.Encode(&buf, worldmap.FormatText))
+	assert.Equal(t, "Bar\nBaz\n", buf.String())
+	reread, err := worldmap.Decode(&buf, worldmap.FormatText)
+	require.Nil(t, err)
+	assert.Equal(t, []worldmap.City{"Bar", "Baz"}, reread.GetCities())
+}
+
+func TestParseOneWayRoads(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(`Foo north->"Bar->Baz" south=Qu-ux`))
+	require.Nil(t, err)
+	assert.Equal(t, []worldmap.City{"Foo", "Bar->Baz", "Qu-ux"}, worldMap.GetCities())
+	assert.True(t, worldMap.IsOneWay("Foo", worldmap.North))
+
+	var buf bytes.Buffer
+	_, err = worldMap.WriteTo(&buf)
+	require.Nil(t, err)
+	assert.Equal(t, "Foo north->\"Bar->Baz\" south=Qu-ux\n\"Bar->Baz\"\nQu-ux north=Foo\n", buf.String())
+
+	for _, input := range []string{`Foo north->Bar=Baz`, `Foo north->`, `Foo ->Bar`, `Foo north->Bar
+Bar south=Foo`} {
+		_, err := worldmap.InitWorldMap(strings.NewReader(input))
+		assert.NotNil(t, err, input)
+	}
+}
@@ -0,0 +1,426 @@
+package worldmap
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	wmerror "github.com/harry-hov/alien-invasion/error"
+)
+
+// Choice is the situation of an alien choosing where to go
+type Choice struct {
+	WorldMap *WorldMap
+	Alien    Alien
+	City     City
+	// Exits are the directions leading out of the city
+	// in the order of the direction system, never empty
+	Exits []Direction
+	// Heading is the direction of the last road the alien took,
+	// empty before its first move
+	Heading Direction
+}
+
+// MoveStrategy chooses where an alien goes on each move
+// Strategies may be called concurrently (see ParallelRandWalkAlien),
+// so they must not modify the WorldMap, and they must only draw from
+// random for invasions to be reproducible.
+// Strategies are saved with the aliens as their String, so they can
+// only be given to aliens once registered (see RegisterStrategy).
+type MoveStrategy interface {
+	// Choose returns the index in c.Exits of the direction taken,
+	// or -1 to stay in the city
+	Choose(c *Choice, random *rand.Rand) int
+	// String returns the strategy as parsed by ParseStrategy
+	String() string
+}
+
+// StrategyParser returns the strategy written with the parameters
+// after its name, empty if there are none. Directions must belong to ds,
+// or to any registered direction system if ds is nil.
+type StrategyParser func(param string, ds *DirectionSystem) (MoveStrategy, error)
+
+// Built-in move strategies by name
+const (
+	StrategyRandomWalk    = "random"
+	StrategyStayPut       = "stay-put"
+	StrategyLazyWalk      = "lazy"
+	StrategyAvoidOccupied = "avoid-occupied"
+	StrategySeekNearest   = "seek-nearest"
+	StrategyFollowWall    = "follow-wall"
+	StrategyBiased        = "biased"
+)
+
+// DefaultSeekRadius is the number of roads SeekNearest looks through
+const DefaultSeekRadius = 10
+
+var strategies = struct {
+	sync.RWMutex
+	names  []string
+	byName map[string]StrategyParser
+}{
+	names: []string{
+		StrategyRandomWalk, StrategyStayPut, StrategyLazyWalk, StrategyAvoidOccupied,
+		StrategySeekNearest, StrategyFollowWall, StrategyBiased,
+	},
+	byName: map[string]StrategyParser{
+		StrategyRandomWalk:    withoutParam(RandomWalk{}),
+		StrategyStayPut:       parseStayPut,
+		StrategyLazyWalk:      withoutParam(LazyWalk{}),
+		StrategyAvoidOccupied: withoutParam(AvoidOccupied{}),
+		StrategySeekNearest:   parseSeekNearest,
+		StrategyFollowWall:    withoutParam(FollowWall{}),
+		StrategyBiased:        parseBiased,
+	},
+}
+
+// RegisterStrategy makes the strategy available by name to ParseStrategy,
+// e.g to give it to aliens and decode world documents. Strategies
+// must be written as the name, followed by ":" and their parameters if any.
+func RegisterStrategy(name string, parse StrategyParser) error {
+	if name == "" || strings.Contains(name, ":") {
+		return wmerror.Wrap(wmerror.ErrInvalidStrategy, fmt.Sprintf("invalid strategy name (%v)", name))
+	}
+	strategies.Lock()
+	defer strategies.Unlock()
+	if _, ok := strategies.byName[name]; ok {
+		return wmerror.Wrap(wmerror.ErrInvalidStrategy, fmt.Sprintf("strategy (%v) already registered", name))
+	}
+	strategies.names = append(strategies.names, name)
+	strategies.byName[name] = parse
+	return nil
+}
+
+// Strategies returns the names of the registered move strategies,
+// built-in strategies first
+func Strategies() []string {
+	strategies.RLock()
+	defer strategies.RUnlock()
+	return append([]string(nil), strategies.names...)
+}
+
+// ParseStrategy returns the registered strategy written as "name"
+// or "name:parameters", e.g "stay-put:0.3" or "biased:north=3,east=2"
+// Directions must belong to ds, or to any registered direction
+// system if ds is nil.
+func ParseStrategy(spec string, ds *DirectionSystem) (MoveStrategy, error) {
+	name, param, hasParam := strings.Cut(spec, ":")
+	strategies.RLock()
+	parse, ok := strategies.byName[name]
+	strategies.RUnlock()
+	var err error
+	switch {
+	case !ok:
+		err = fmt.Errorf("is unknown, expected one of %v", strings.Join(Strategies(), "|"))
+	case hasParam && param == "":
+		err = fmt.Errorf("has empty parameters")
+	default:
+		var strategy MoveStrategy
+		if strategy, err = parse(param, ds); err == nil {
+			return strategy, nil
+		}
+	}
+	return nil, wmerror.Wrap(wmerror.ErrInvalidStrategy, fmt.Sprintf("(%v) %v", spec, err))
+}
+
+// withoutParam parses the strategy taking no parameter
+func withoutParam(s MoveStrategy) StrategyParser {
+	return func(param string, _ *DirectionSystem) (MoveStrategy, error) {
+		if param != "" {
+			return nil, fmt.Errorf("takes no parameter")
+		}
+		return s, nil
+	}
+}
+
+func parseStayPut(param string, _ *DirectionSystem) (MoveStrategy, error) {
+	p, err := strconv.ParseFloat(param, 64)
+	if err != nil || !(p >= 0 && p <= 1) {
+		return nil, fmt.Errorf("needs a probability between 0 and 1, e.g stay-put:0.5")
+	}
+	return StayPut{P: p}, nil
+}
+
+func parseSeekNearest(param string, _ *DirectionSystem) (MoveStrategy, error) {
+	if param == "" {
+		return SeekNearest{Radius: DefaultSeekRadius}, nil
+	}
+	radius, err := strconv.Atoi(param)
+	if err != nil || radius < 1 {
+		return nil, fmt.Errorf("needs a positive radius, e.g seek-nearest:5")
+	}
+	return SeekNearest{Radius: radius}, nil
+}
+
+func parseBiased(param string, ds *DirectionSystem) (MoveStrategy, error) {
+	weights := make(map[Direction]float64)
+	for _, field := range strings.Split(param, ",") {
+		direction, value, ok := strings.Cut(field, "=")
+		weight, err := strconv.ParseFloat(value, 64)
+		if !ok || direction == "" || err != nil || !(weight >= 0) || math.IsInf(weight, 1) {
+			return nil, fmt.Errorf("needs direction weights, e.g biased:north=3,east=2")
+		}
+		weights[Direction(strings.ToLower(direction))] = weight
+	}
+	for direction := range weights {
+		if (ds != nil && !ds.IsValid(direction)) || (ds == nil && !isRegisteredDirection(direction)) {
+			return nil, fmt.Errorf("unknown direction (%v)", direction)
+		}
+	}
+	return Biased{Weights: weights}, nil
+}
+
+// RandomWalk takes any direction out of the city
+type RandomWalk struct{}
+
+func (RandomWalk) Choose(c *Choice, random *rand.Rand) int {
+	return random.Intn(len(c.Exits))
+}
+
+func (RandomWalk) String() string {
+	return StrategyRandomWalk
+}
+
+// StayPut stays in the city with probability P, or walks randomly
+type StayPut struct {
+	P float64
+}
+
+func (s StayPut) Choose(c *Choice, random *rand.Rand) int {
+	if random.Float64() < s.P {
+		return -1
+	}
+	return random.Intn(len(c.Exits))
+}
+
+func (s StayPut) String() string {
+	return StrategyStayPut + ":" + formatFloat(s.P)
+}
+
+// LazyWalk takes any of the roads out of the city
+// taking the fewest moves to travel
+type LazyWalk struct{}
+
+func (LazyWalk) Choose(c *Choice, random *rand.Rand) int {
+	var cheapest []int
+	minCost := 0
+	for i, direction := range c.Exits {
+		cost := c.WorldMap.GetRoadCost(c.City, direction)
+		if cheapest == nil || cost < minCost {
+			cheapest, minCost = []int{i}, cost
+		} else if cost == minCost {
+			cheapest = append(cheapest, i)
+		}
+	}
+	return cheapest[random.Intn(len(cheapest))]
+}
+
+func (LazyWalk) String() string {
+	return StrategyLazyWalk
+}
+
+// AvoidOccupied takes any direction leading to a city without aliens,
+// or any direction if they all lead to occupied cities
+type AvoidOccupied struct{}
+
+func (AvoidOccupied) Choose(c *Choice, random *rand.Rand) int {
+	var empty []int
+	for i, direction := range c.Exits {
+		if city, _ := c.WorldMap.GetDirectionCity(c.City, direction); c.WorldMap.GetCityAlienCount(city) == 0 {
+			empty = append(empty, i)
+		}
+	}
+	if empty == nil {
+		return random.Intn(len(c.Exits))
+	}
+	return empty[random.Intn(len(empty))]
+}
+
+func (AvoidOccupied) String() string {
+	return StrategyAvoidOccupied
+}
+
+// SeekNearest heads for the nearest city with aliens in it,
+// up to Radius roads away, and stays if there are already aliens
+// in its city. Without aliens in sight it walks randomly.
+type SeekNearest struct {
+	Radius int
+}
+
+func (s SeekNearest) Choose(c *Choice, random *rand.Rand) int {
+	if c.WorldMap.GetCityAlienCount(c.City) > 1 {
+		return -1
+	}
+
+	// Search the cities around, remembering the exit leading to them
+	type step struct {
+		city City
+		exit int
+	}
+	seen := map[City]bool{c.City: true}
+	var frontier []step
+	for i, direction := range c.Exits {
+		city, _ := c.WorldMap.GetDirectionCity(c.City, direction)
+		if !seen[city] {
+			seen[city] = true
+			frontier = append(frontier, step{city, i})
+		}
+	}
+	for distance := 1; distance <= s.Radius && frontier != nil; distance++ {
+		var next []step
+		for _, st := range frontier {
+			if c.WorldMap.GetCityAlienCount(st.city) > 0 {
+				return st.exit
+			}
+			for _, city := range c.WorldMap.GetConnectedCities(st.city) {
+				if !seen[city] {
+					seen[city] = true
+					next = append(next, step{city, st.exit})
+				}
+			}
+		}
+		frontier = next
+	}
+	return random.Intn(len(c.Exits))
+}
+
+func (s SeekNearest) String() string {
+	return StrategySeekNearest + ":" + strconv.Itoa(s.Radius)
+}
+
+// FollowWall keeps a hand on the wall to its right: it takes the first
+// direction out of the city turning right from its heading, then straight
+// ahead, left and back. Directions are turned through in the order of the
+// direction system, listed clockwise by the built-in planar systems.
+// Before its first move it walks randomly.
+type FollowWall struct{}
+
+func (FollowWall) Choose(c *Choice, random *rand.Rand) int {
+	ds := c.WorldMap.GetDirectionSystem()
+	heading, ok := ds.index[c.Heading]
+	if !ok {
+		return random.Intn(len(c.Exits))
+	}
+	n := len(ds.directions)
+	back := ds.opposite[heading]
+	for k := 1; k <= n; k++ {
+		direction := ds.directions[((back-k)%n+n)%n]
+		for i, exit := range c.Exits {
+			if exit == direction {
+				return i
+			}
+		}
+	}
+	return random.Intn(len(c.Exits))
+}
+
+func (FollowWall) String() string {
+	return StrategyFollowWall
+}
+
+// Biased takes directions with probabilities proportional
+// to their weights, 1 for directions without a weight
+type Biased struct {
+	Weights map[Direction]float64
+}
+
+func (b Biased) Choose(c *Choice, random *rand.Rand) int {
+	total := 0.0
+	for _, direction := range c.Exits {
+		total += b.weight(direction)
+	}
+	if total == 0 {
+		return -1
+	}
+	draw := random.Float64() * total
+	for i, direction := range c.Exits {
+		if draw -= b.weight(direction); draw < 0 {
+			return i
+		}
+	}
+	return len(c.Exits) - 1
+}
+
+func (b Biased) weight(d Direction) float64 {
+	if weight, ok := b.Weights[d]; ok {
+		return weight
+	}
+	return 1
+}
+
+func (b Biased) String() string {
+	weights := make([]string, 0, len(b.Weights))
+	for direction, weight := range b.Weights {
+		weights = append(weights, fmt.Sprintf("%v=%v", direction, formatFloat(weight)))
+	}
+	sort.Strings(weights)
+	return StrategyBiased + ":" + strings.Join(weights, ",")
+}
+
+// SetAlienStrategy makes the alien move with the strategy,
+// a nil strategy is a random walk
+// The strategy must parse back from its String with the direction
+// system of the WorldMap, so the alien can be saved and restored.
+func (wm *WorldMap) SetAlienStrategy(a Alien, s MoveStrategy) error {
+	id, ok := wm.alienIDs[a]
+	if !ok {
+		return &wmerror.MapError{
+			Err:         wmerror.ErrInvalidAlien,
+			Description: fmt.Sprintf("unknown alien (%v)", a),
+			Alien:       string(a),
+		}
+	}
+	if _, ok := s.(RandomWalk); ok || s == nil {
+		wm.strategies[id] = nil
+		return nil
+	}
+	if _, err := ParseStrategy(s.String(), wm.directions); err != nil {
+		return err
+	}
+	wm.strategies[id] = s
+	return nil
+}
+
+// GetAlienStrategy returns the strategy of the alien
+func (wm *WorldMap) GetAlienStrategy(a Alien) MoveStrategy {
+	id, ok := wm.alienIDs[a]
+	if !ok || wm.strategies[id] == nil {
+		return RandomWalk{}
+	}
+	return wm.strategies[id]
+}
+
+// GetAlienHeading returns the direction of the last road
+// the alien took, empty if it has not moved yet
+func (wm *WorldMap) GetAlienHeading(a Alien) Direction {
+	id, ok := wm.alienIDs[a]
+	if !ok || wm.headings[id] < 0 {
+		return ""
+	}
+	return wm.directions.directions[wm.headings[id]]
+}
+
+// choose returns the index in exits of the direction
+// taken by the alien with a strategy, or -1 to stay
+func (wm *WorldMap) choose(id alienID, exits []int32, random *rand.Rand) int {
+	c := &Choice{
+		WorldMap: wm,
+		Alien:    wm.alienNames[id],
+		City:     wm.cityNames[wm.alienCities[id]],
+		Exits:    make([]Direction, len(exits)),
+	}
+	if heading := wm.headings[id]; heading >= 0 {
+		c.Heading = wm.directions.directions[heading]
+	}
+	for i, d := range exits {
+		c.Exits[i] = wm.directions.directions[d]
+	}
+	if i := wm.strategies[id].Choose(c, random); i >= 0 && i < len(exits) {
+		return i
+	}
+	return -1
+}
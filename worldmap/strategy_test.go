@@ -0,0 +1,286 @@
+package worldmap_test
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+
+	wmerror "github.com/harry-hov/alien-invasion/error"
+	"github.com/harry-hov/alien-invasion/worldmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStrategy(t *testing.T) {
+	for spec, expected := range map[string]string{
+		"random":                "random",
+		"stay-put:0.25":         "stay-put:0.25",
+		"lazy":                  "lazy",
+		"avoid-occupied":        "avoid-occupied",
+		"seek-nearest":          "seek-nearest:10",
+		"seek-nearest:3":        "seek-nearest:3",
+		"follow-wall":           "follow-wall",
+		"biased:North=3,east=0": "biased:east=0,north=3",
+	} {
+		strategy, err := worldmap.ParseStrategy(spec, nil)
+		require.Nil(t, err, spec)
+		assert.Equal(t, expected, strategy.String(), spec)
+
+		// Strategies parse back from their string
+		again, err := worldmap.ParseStrategy(strategy.String(), nil)
+		require.Nil(t, err, spec)
+		assert.Equal(t, strategy, again, spec)
+	}
+
+	for _, spec := range []string{
+		"", "unknown", "random:1", "lazy:", "stay-put", "stay-put:1.5", "stay-put:-0.1", "stay-put:NaN",
+		"seek-nearest:0", "seek-nearest:x", "biased", "biased:north", "biased:north=-1", "biased:=2", "biased:north=inf",
+		"biased:nort=3",
+	} {
+		_, err := worldmap.ParseStrategy(spec, nil)
+		assert.True(t, errors.Is(err, wmerror.ErrInvalidStrategy), spec)
+	}
+
+	// Directions are checked against the direction system of the WorldMap
+	_, err := worldmap.ParseStrategy("biased:up=2", worldmap.Vertical)
+	assert.Nil(t, err)
+	_, err = worldmap.ParseStrategy("biased:up=2", worldmap.Compass)
+	assert.True(t, errors.Is(err, wmerror.ErrInvalidStrategy))
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(`Foo north=Bar`))
+	require.Nil(t, err)
+	require.Nil(t, worldMap.AddAlien("alien-0", "Foo"))
+	err = worldMap.SetAlienStrategy("alien-0", worldmap.Biased{Weights: map[worldmap.Direction]float64{"up": 2}})
+	assert.True(t, errors.Is(err, wmerror.ErrInvalidStrategy))
+}
+
+// strategyWorldMap returns a WorldMap with aliens placed in cities,
+// moving with strategies
+func strategyWorldMap(t *testing.T, input string, seed int64, aliens map[worldmap.Alien]worldmap.City, strategies map[worldmap.Alien]string) *worldmap.WorldMap {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(input))
+	require.Nil(t, err)
+	worldMap.SetRand(rand.New(rand.NewSource(seed)))
+	for _, alien := range []worldmap.Alien{"alien-0", "alien-1"} {
+		if city, ok := aliens[alien]; ok {
+			require.Nil(t, worldMap.AddAlien(alien, city))
+		}
+	}
+	for alien, spec := range strategies {
+		strategy, err := worldmap.ParseStrategy(spec, nil)
+		require.Nil(t, err)
+		require.Nil(t, worldMap.SetAlienStrategy(alien, strategy))
+	}
+	return worldMap
+}
+
+func TestStayPut(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		worldMap := strategyWorldMap(t, `Foo north=Bar east=Baz`, seed,
+			map[worldmap.Alien]worldmap.City{"alien-0": "Foo"}, map[worldmap.Alien]string{"alien-0": "stay-put:1"})
+		for move := 0; move < 10; move++ {
+			assert.Nil(t, worldMap.RandWalkAlien())
+		}
+
+		worldMap = strategyWorldMap(t, `Foo north=Bar east=Baz`, seed,
+			map[worldmap.Alien]worldmap.City{"alien-0": "Foo"}, map[worldmap.Alien]string{"alien-0": "stay-put:0"})
+		assert.Len(t, worldMap.RandWalkAlien(), 1)
+	}
+}
+
+func TestLazyWalk(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		worldMap := strategyWorldMap(t, `Foo north=Bar:3 east=Baz south=Qux west=Quux:2`, seed,
+			map[worldmap.Alien]worldmap.City{"alien-0": "Foo"}, map[worldmap.Alien]string{"alien-0": "lazy"})
+		moves := worldMap.RandWalkAlien()
+		require.Len(t, moves, 1)
+		assert.Contains(t, []worldmap.City{"Baz", "Qux"}, moves[0].To)
+	}
+}
+
+func TestAvoidOccupied(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		worldMap := strategyWorldMap(t, `Foo north=Bar east=Baz`, seed,
+			map[worldmap.Alien]worldmap.City{"alien-0": "Foo", "alien-1": "Bar"},
+			map[worldmap.Alien]string{"alien-0": "avoid-occupied", "alien-1": "stay-put:1"})
+		worldMap.RandWalkAlien()
+		assert.Equal(t, worldmap.City("Baz"), worldMap.GetAliens()["alien-0"])
+	}
+
+	// Aliens go anywhere when all cities around are occupied
+	worldMap := strategyWorldMap(t, `Foo north=Bar`, 1,
+		map[worldmap.Alien]worldmap.City{"alien-0": "Foo", "alien-1": "Bar"},
+		map[worldmap.Alien]string{"alien-0": "avoid-occupied", "alien-1": "stay-put:1"})
+	worldMap.RandWalkAlien()
+	assert.Equal(t, worldmap.City("Bar"), worldMap.GetAliens()["alien-0"])
+}
+
+func TestSeekNearest(t *testing.T) {
+	const input = `Foo west=Bar east=Baz
+Baz east=Qux
+`
+	for seed := int64(0); seed < 10; seed++ {
+		worldMap := strategyWorldMap(t, input, seed,
+			map[worldmap.Alien]worldmap.City{"alien-0": "Foo", "alien-1": "Qux"},
+			map[worldmap.Alien]string{"alien-0": "seek-nearest", "alien-1": "stay-put:1"})
+		worldMap.RandWalkAlien()
+		assert.Equal(t, worldmap.City("Baz"), worldMap.GetAliens()["alien-0"])
+		worldMap.RandWalkAlien()
+		assert.Equal(t, worldmap.City("Qux"), worldMap.GetAliens()["alien-0"])
+
+		// Aliens stay once they found other aliens
+		assert.Nil(t, worldMap.RandWalkAlien())
+	}
+}
+
+func TestFollowWall(t *testing.T) {
+	const input = `Foo north=Bar east=Baz west=Qux
+Quux north=Foo
+`
+	worldMap := strategyWorldMap(t, input, 1,
+		map[worldmap.Alien]worldmap.City{"alien-0": "Quux"}, map[worldmap.Alien]string{"alien-0": "follow-wall"})
+	assert.Equal(t, worldmap.Direction(""), worldMap.GetAlienHeading("alien-0"))
+	require.Nil(t, worldMap.MoveAlien("alien-0", "Foo"))
+	assert.Equal(t, worldmap.North, worldMap.GetAlienHeading("alien-0"))
+
+	// Heading north, the wall is on the right, then back
+	// the only way out of the dead end
+	for _, expected := range []worldmap.City{"Baz", "Foo", "Bar", "Foo", "Qux"} {
+		worldMap.RandWalkAlien()
+		assert.Equal(t, expected, worldMap.GetAliens()["alien-0"])
+	}
+}
+
+func TestBiased(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		worldMap := strategyWorldMap(t, `Foo north=Bar east=Baz`, seed,
+			map[worldmap.Alien]worldmap.City{"alien-0": "Foo"}, map[worldmap.Alien]string{"alien-0": "biased:north=0"})
+		worldMap.RandWalkAlien()
+		assert.Equal(t, worldmap.City("Baz"), worldMap.GetAliens()["alien-0"])
+
+		// Aliens without a way to go stay
+		worldMap = strategyWorldMap(t, `Foo north=Bar east=Baz`, seed,
+			map[worldmap.Alien]worldmap.City{"alien-0": "Foo"}, map[worldmap.Alien]string{"alien-0": "biased:north=0,east=0"})
+		assert.Nil(t, worldMap.RandWalkAlien())
+	}
+}
+
+func TestAlienStrategy(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	require.Nil(t, err)
+	require.Nil(t, worldMap.AddAlien("alien-0", "Foo"))
+	require.Nil(t, worldMap.AddAlien("alien-1", "Foo"))
+	assert.Equal(t, worldmap.RandomWalk{}, worldMap.GetAlienStrategy("alien-0"))
+	assert.NotNil(t, worldMap.SetAlienStrategy("unknown", worldmap.LazyWalk{}))
+
+	require.Nil(t, worldMap.SetAlienStrategy("alien-0", worldmap.StayPut{P: 0.5}))
+	require.Nil(t, worldMap.SetAlienStrategy("alien-1", worldmap.FollowWall{}))
+	require.Nil(t, worldMap.MoveAlien("alien-1", "Bar"))
+	clone := worldMap.Clone()
+	assert.Equal(t, worldmap.StayPut{P: 0.5}, clone.GetAlienStrategy("alien-0"))
+
+	// Strategies and headings are encoded with the aliens
+	for _, format := range []worldmap.Format{worldmap.FormatJSON, worldmap.FormatYAML} {
+		var buf bytes.Buffer
+		require.Nil(t, worldMap.Encode(&buf, format))
+		decoded, err := worldmap.Decode(&buf, format)
+		require.Nil(t, err, format)
+		assert.Equal(t, worldmap.StayPut{P: 0.5}, decoded.GetAlienStrategy("alien-0"), format)
+		assert.Equal(t, worldmap.FollowWall{}, decoded.GetAlienStrategy("alien-1"), format)
+		assert.Equal(t, worldmap.Direction(""), decoded.GetAlienHeading("alien-0"), format)
+		assert.Equal(t, worldmap.North, decoded.GetAlienHeading("alien-1"), format)
+	}
+
+	for _, input := range []string{
+		`{"cities":[{"name":"Foo"}],"aliens":[{"name":"alien-0","city":"Foo","strategy":"unknown"}]}`,
+		`{"cities":[{"name":"Foo"}],"aliens":[{"name":"alien-0","city":"Foo","heading":"up"}]}`,
+	} {
+		_, err := worldmap.DecodeJSON(strings.NewReader(input))
+		assert.NotNil(t, err, input)
+	}
+}
+
+// eastward is a custom strategy heading east whenever it can
+type eastward struct{}
+
+func (eastward) Choose(c *worldmap.Choice, _ *rand.Rand) int {
+	for i, direction := range c.Exits {
+		if direction == worldmap.East {
+			return i
+		}
+	}
+	return -1
+}
+
+func (eastward) String() string {
+	return "eastward"
+}
+
+// unregistered is a custom strategy missing from the registry
+type unregistered struct{ eastward }
+
+func (unregistered) String() string {
+	return "unregistered"
+}
+
+var _ = worldmap.RegisterStrategy("eastward", func(param string, _ *worldmap.DirectionSystem) (worldmap.MoveStrategy, error) {
+	if param != "" {
+		return nil, errors.New("takes no parameter")
+	}
+	return eastward{}, nil
+})
+
+func TestRegisterStrategy(t *testing.T) {
+	assert.Contains(t, worldmap.Strategies(), "eastward")
+	assert.NotNil(t, worldmap.RegisterStrategy("eastward", nil))
+	assert.NotNil(t, worldmap.RegisterStrategy(worldmap.StrategyLazyWalk, nil))
+	assert.NotNil(t, worldmap.RegisterStrategy("east:ward", nil))
+	_, err := worldmap.ParseStrategy("eastward:1", nil)
+	assert.True(t, errors.Is(err, wmerror.ErrInvalidStrategy))
+
+	worldMap := strategyWorldMap(t, `Foo north=Bar east=Baz`, 1,
+		map[worldmap.Alien]worldmap.City{"alien-0": "Foo", "alien-1": "Foo"}, map[worldmap.Alien]string{"alien-0": "eastward"})
+	err = worldMap.SetAlienStrategy("alien-1", unregistered{})
+	assert.True(t, errors.Is(err, wmerror.ErrInvalidStrategy))
+
+	// Registered strategies are restored with the aliens
+	for _, format := range []worldmap.Format{worldmap.FormatJSON, worldmap.FormatYAML} {
+		var buf bytes.Buffer
+		require.Nil(t, worldMap.Encode(&buf, format))
+		decoded, err := worldmap.Decode(&buf, format)
+		require.Nil(t, err, format)
+		assert.Equal(t, eastward{}, decoded.GetAlienStrategy("alien-0"), format)
+		decoded.RandWalkAlien()
+		assert.Equal(t, worldmap.City("Baz"), decoded.GetAliens()["alien-0"], format)
+	}
+}
+
+func TestStrategyOccupancy(t *testing.T) {
+	walk := func(seed int64, shards int) (moves [][]worldmap.Move) {
+		worldMap, err := worldmap.InitWorldMap(strings.NewReader(occupancyWorldMapInput))
+		require.Nil(t, err)
+		worldMap.SetRand(rand.New(rand.NewSource(seed)))
+		worldMap.UnleaseNAliens(14)
+		for i, alien := range worldMap.GetAlienList() {
+			spec := []string{"random", "stay-put:0.5", "lazy", "avoid-occupied", "seek-nearest:2", "follow-wall", "biased:north=2,west=0"}[i%7]
+			strategy, err := worldmap.ParseStrategy(spec, nil)
+			require.Nil(t, err)
+			require.Nil(t, worldMap.SetAlienStrategy(alien, strategy))
+		}
+		for move := 0; move < 10; move++ {
+			if shards > 0 {
+				moves = append(moves, worldMap.ParallelRandWalkAlien(shards))
+			} else {
+				moves = append(moves, worldMap.RandWalkAlien())
+			}
+			checkOccupancy(t, worldMap)
+		}
+		return
+	}
+
+	for seed := int64(0); seed < 5; seed++ {
+		for _, shards := range []int{0, 1, 4} {
+			assert.Equal(t, walk(seed, shards), walk(seed, shards), shards)
+		}
+	}
+}
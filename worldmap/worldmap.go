@@ -4,8 +4,12 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"math"
 	"math/rand"
-	"strings"
+	"os"
+	"sort"
+	"strconv"
+	"time"
 
 	wmerror "github.com/harry-hov/alien-invasion/error"
 )
@@ -21,250 +25,849 @@ const (
 	West  = Direction("west")
 )
 
-// IsValid checks if direction is valid
+// Directions lists the Compass directions in the order
+// they are iterated over by the WorldMap
+var Directions = []Direction{North, East, South, West}
+
+// IsValid checks if direction is a Compass direction
 func (d Direction) IsValid() bool {
-	return d == East || d == North || d == South || d == West
+	return Compass.IsValid(d)
 }
 
-// Get opposite direction
+// Get opposite Compass direction
 // e.g North.Opposite() == South
 func (d Direction) GetOpposite() (Direction, error) {
-	switch d {
-	case East:
-		return West, nil
-	case North:
-		return South, nil
-	case South:
-		return North, nil
-	case West:
-		return East, nil
-	}
-	return Direction(""), wmerror.Wrap(wmerror.ErrInvalidDirection, fmt.Sprintf("(%v)", d))
+	return Compass.Opposite(d)
+}
+
+// Move records an alien walking from one city to another
+type Move struct {
+	Alien Alien
+	From  City
+	To    City
 }
 
+// WorldMap keeps cities in the order they were added and aliens
+// in the order they were unleashed, so that iterating over the
+// world (and therefore every random draw) is deterministic.
+// Cities and aliens are interned: each gets an ID, its position in
+// cityNames or alienNames, when it is added. Destroyed cities and killed
+// aliens leave a hole, so IDs never change and iterating over the IDs
+// follows the order cities were added and aliens unleashed.
 type WorldMap struct {
-	cities map[City]map[Direction]City
-	aliens map[Alien]City
+	cityIDs   map[City]cityID
+	cityNames []City
+	destroyed []bool
+	cityCount int
+	// roads are the roads leaving each city in the order of the direction
+	// system, the road of city id in the d-th direction is
+	// roads[id*len(directions)+d]
+	roads []road
+	// coordinates of the cities placed on a map
+	coordinates map[cityID]Vector
+	alienIDs    map[Alien]alienID
+	alienNames  []Alien
+	// alienCities is the city of each alien, noCity once it is killed
+	alienCities []cityID
+	alienCount  int
+	// transit holds the moves left for aliens travelling to their city
+	transit    []int32
+	travelling int
+	// strategies of the aliens not walking randomly and headings,
+	// the index of the direction they last took (-1 before moving)
+	strategies []MoveStrategy
+	headings   []int32
+	// occupants counts the aliens in each city. The aliens in each city
+	// and travelling to it are linked through next and prev, starting
+	// at residents and inbound.
+	occupants []int32
+	residents []alienID
+	inbound   []alienID
+	next      []alienID
+	prev      []alienID
+	// crowded holds the cities with two aliens or more and
+	// trappedCities the occupied cities without a way out,
+	// trapped counts the aliens in them
+	crowded       citySet
+	trappedCities citySet
+	trapped       int
+	// newlyTrapped are the aliens trapped since TakeNewlyTrapped
+	newlyTrapped []alienID
+	// live and choices are the buffers of ParallelRandWalkAlien
+	live       []alienID
+	choices    []int32
+	directions *DirectionSystem
+	rand       *rand.Rand
+}
+
+// cityID and alienID index the interned cities and aliens
+type cityID int32
+type alienID int32
+
+// noCity marks roads leading nowhere and aliens killed
+const noCity = cityID(-1)
+
+// maxCost is the highest cost of a road
+const maxCost = math.MaxInt32
+
+// road is a slot of the flat adjacency array of the WorldMap
+type road struct {
+	to   cityID
+	cost int32
+	// blocked marks the reverse side of one-way roads, the direction
+	// still leads to the other city but cannot be traveled
+	blocked bool
+}
+
+var noRoad = road{to: noCity}
+
+// open checks if the road can be traveled
+func (r road) open() bool {
+	return r.to != noCity && !r.blocked
 }
 
-// Returns empty WorldMap
+// Returns empty WorldMap using the Compass directions
 func New() *WorldMap {
+	return NewWithDirections(Compass)
+}
+
+// NewWithDirections returns empty WorldMap using the direction system
+func NewWithDirections(ds *DirectionSystem) *WorldMap {
 	return &WorldMap{
-		cities: make(map[City]map[Direction]City),
-		aliens: make(map[Alien]City),
+		cityIDs:     make(map[City]cityID),
+		coordinates: make(map[cityID]Vector),
+		alienIDs:    make(map[Alien]alienID),
+		directions:  ds,
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
 }
 
-// InitWorldMap returns WorldMap from io.Reader
-func InitWorldMap(reader io.Reader) (*WorldMap, error) {
-	scanner := bufio.NewScanner(reader)
-	worldMap := New()
+// Clone returns an independent copy of the WorldMap
+// with the same cities, directions and alien placements.
+// The copy gets its own random number generator, see SetRand.
+func (wm *WorldMap) Clone() *WorldMap {
+	clone := NewWithDirections(wm.directions)
+	for city, id := range wm.cityIDs {
+		clone.cityIDs[city] = id
+	}
+	for id, coordinates := range wm.coordinates {
+		clone.coordinates[id] = coordinates
+	}
+	for alien, id := range wm.alienIDs {
+		clone.alienIDs[alien] = id
+	}
+	clone.cityNames = append([]City(nil), wm.cityNames...)
+	clone.destroyed = append([]bool(nil), wm.destroyed...)
+	clone.cityCount = wm.cityCount
+	clone.roads = append([]road(nil), wm.roads...)
+	clone.alienNames = append([]Alien(nil), wm.alienNames...)
+	clone.alienCities = append([]cityID(nil), wm.alienCities...)
+	clone.alienCount = wm.alienCount
+	clone.transit = append([]int32(nil), wm.transit...)
+	clone.strategies = append([]MoveStrategy(nil), wm.strategies...)
+	clone.headings = append([]int32(nil), wm.headings...)
+	clone.travelling = wm.travelling
+	clone.occupants = append([]int32(nil), wm.occupants...)
+	clone.residents = append([]alienID(nil), wm.residents...)
+	clone.inbound = append([]alienID(nil), wm.inbound...)
+	clone.next = append([]alienID(nil), wm.next...)
+	clone.prev = append([]alienID(nil), wm.prev...)
+	clone.crowded = wm.crowded.clone()
+	clone.trappedCities = wm.trappedCities.clone()
+	clone.trapped = wm.trapped
+	clone.newlyTrapped = append([]alienID(nil), wm.newlyTrapped...)
+	return clone
+}
 
-	for scanner.Scan() {
-		line := scanner.Text()
+// GetDirectionSystem returns the direction system of the WorldMap
+func (wm *WorldMap) GetDirectionSystem() *DirectionSystem {
+	return wm.directions
+}
 
-		// Skip blank lines
-		if len(strings.TrimSpace(line)) == 0 {
-			continue
-		}
+// SetRand sets the random number generator used by the WorldMap
+func (wm *WorldMap) SetRand(r *rand.Rand) {
+	wm.rand = r
+}
 
-		// Tokenize line
-		tokens := strings.Split(line, " ")
-		city := City(tokens[0])
-		if len(tokens) < 2 {
-			return nil, wmerror.Wrap(wmerror.ErrInvalidCity, fmt.Sprintf("isolated city (%v)", city))
-		}
+// InitWorldMap returns WorldMap from io.Reader
+// It fails on the first problem found, see Parse.
+func InitWorldMap(reader io.Reader) (*WorldMap, error) {
+	return Parse(reader, ParseOptions{})
+}
 
-		// Add city to WorldMap
-		worldMap.AddCity(city)
+// cityRoads returns the road slots of the city
+func (wm *WorldMap) cityRoads(id cityID) []road {
+	width := len(wm.directions.directions)
+	return wm.roads[int(id)*width : (int(id)+1)*width]
+}
 
-		for _, token := range tokens[1:] {
-			directionEntry := strings.Split(token, "=")
-			if len(directionEntry) != 2 {
-				return nil, wmerror.Wrap(wmerror.ErrInvalidDirection, "cannot parse direction entry")
-			}
-			direction := Direction(strings.ToLower(directionEntry[0]))
-			if !direction.IsValid() {
-				return nil, wmerror.Wrap(wmerror.ErrInvalidDirection, "cannot parse direction")
-			}
-			directionCity := City(directionEntry[1])
-			if err := worldMap.AppendCityDirection(city, directionCity, direction); err != nil {
-				return nil, err
-			}
-		}
+// road returns the road of the city in the d-th direction
+func (wm *WorldMap) road(id cityID, d int) *road {
+	return &wm.roads[int(id)*len(wm.directions.directions)+d]
+}
+
+// lookup returns the road of the city in the direction
+func (wm *WorldMap) lookup(c City, d Direction) (road, bool) {
+	id, ok := wm.cityIDs[c]
+	if !ok {
+		return noRoad, false
+	}
+	index, ok := wm.directions.index[d]
+	if !ok {
+		return noRoad, false
 	}
+	r := *wm.road(id, index)
+	return r, r.to != noCity
+}
 
-	return worldMap, nil
+// addCity interns the city, if not in WorldMap yet
+func (wm *WorldMap) addCity(c City) cityID {
+	if id, ok := wm.cityIDs[c]; ok {
+		return id
+	}
+	id := cityID(len(wm.cityNames))
+	wm.cityIDs[c] = id
+	wm.cityNames = append(wm.cityNames, c)
+	wm.destroyed = append(wm.destroyed, false)
+	for range wm.directions.directions {
+		wm.roads = append(wm.roads, noRoad)
+	}
+	wm.occupants = append(wm.occupants, 0)
+	wm.residents = append(wm.residents, noAlien)
+	wm.inbound = append(wm.inbound, noAlien)
+	wm.crowded.grow()
+	wm.trappedCities.grow()
+	wm.cityCount++
+	return id
 }
 
 // Add a city to WorldMap
 func (wm *WorldMap) AddCity(c City) {
-	if _, ok := wm.cities[c]; !ok {
-		wm.cities[c] = make(map[Direction]City)
-	}
+	wm.addCity(c)
 }
 
 // Add a city to WorldMap with error
 func (wm *WorldMap) AddCityE(c City) error {
-	if _, ok := wm.cities[c]; ok {
-		return wmerror.Wrap(wmerror.ErrInvalidCity, fmt.Sprintf("duplicate city (%v)", c))
+	if _, ok := wm.cityIDs[c]; ok {
+		return &wmerror.MapError{
+			Err:         wmerror.ErrInvalidCity,
+			Description: fmt.Sprintf("duplicate city (%v)", c),
+			City:        string(c),
+		}
 	}
-	wm.cities[c] = make(map[Direction]City)
+	wm.addCity(c)
 	return nil
 }
 
+// CityCount returns the number of cities in WorldMap
+func (wm *WorldMap) CityCount() int {
+	return wm.cityCount
+}
+
+// AlienCount returns the number of aliens in WorldMap
+func (wm *WorldMap) AlienCount() int {
+	return wm.alienCount
+}
+
+// Road describes how a road can be traveled
+type Road struct {
+	// OneWay roads can only be traveled from the city they leave
+	OneWay bool
+	// Cost is the number of moves it takes to travel the road (default: 1)
+	Cost int
+}
+
 // Append direction to city
+// The road can be traveled both ways in a single move, see AppendRoad.
 func (wm *WorldMap) AppendCityDirection(city, directionCity City, direction Direction) error {
-	if city == directionCity {
-		return wmerror.Wrap(wmerror.ErrInvalidDirection, "city cannot direct to itself")
+	return wm.AppendRoad(city, directionCity, direction, Road{})
+}
+
+// AppendOneWayCityDirection appends a one-way road leading from city to
+// directionCity in the direction. The opposite direction of directionCity
+// still leads to city, but aliens cannot travel it.
+func (wm *WorldMap) AppendOneWayCityDirection(city, directionCity City, direction Direction) error {
+	return wm.AppendRoad(city, directionCity, direction, Road{OneWay: true})
+}
+
+// AppendRoad appends the road leading from city to directionCity
+// in the direction
+func (wm *WorldMap) AppendRoad(city, directionCity City, direction Direction, r Road) error {
+	if r.Cost == 0 {
+		r.Cost = 1
 	}
+	if r.Cost < 0 || r.Cost > maxCost {
+		return &wmerror.MapError{
+			Err:         wmerror.ErrInvalidDirection,
+			Description: fmt.Sprintf("invalid cost (%v) of direction (%v) of (%v)", r.Cost, direction, city),
+			City:        string(city),
+			Direction:   string(direction),
+		}
+	}
+	oneWay := r.OneWay
 
-	// Add directionCity to WorldMap
-	wm.AddCity(directionCity)
-	if val, ok := wm.cities[city][direction]; ok && val != directionCity {
-		return wmerror.Wrap(wmerror.ErrInvalidDirection, fmt.Sprintf("ambiguous direction from city (%v) to (%v)", city, directionCity))
+	if city == directionCity {
+		return &wmerror.MapError{
+			Err:         wmerror.ErrInvalidDirection,
+			Description: fmt.Sprintf("city (%v) cannot direct to itself", city),
+			City:        string(city),
+			Direction:   string(direction),
+		}
 	}
 
-	oppositeDirection, err := direction.GetOpposite()
+	oppositeDirection, err := wm.directions.Opposite(direction)
 	if err != nil {
 		return err
 	}
-	if val, ok := wm.cities[directionCity][oppositeDirection]; ok && val != city {
-		return wmerror.Wrap(wmerror.ErrInvalidDirection, fmt.Sprintf("ambiguous direction from city (%v) to (%v)", directionCity, city))
+
+	existing, ok := wm.lookup(city, direction)
+	if ok && wm.cityNames[existing.to] != directionCity {
+		val := wm.cityNames[existing.to]
+		return &wmerror.MapError{
+			Err:             wmerror.ErrInvalidDirection,
+			Description:     fmt.Sprintf("ambiguous direction (%v) from city (%v) to (%v), already leads to (%v)", direction, city, directionCity, val),
+			City:            string(city),
+			Direction:       string(direction),
+			ConflictingCity: string(val),
+		}
+	}
+
+	if opposite, ok := wm.lookup(directionCity, oppositeDirection); ok && wm.cityNames[opposite.to] != city {
+		val := wm.cityNames[opposite.to]
+		return &wmerror.MapError{
+			Err:             wmerror.ErrInvalidDirection,
+			Description:     fmt.Sprintf("ambiguous direction (%v) from city (%v) to (%v), already leads to (%v)", oppositeDirection, directionCity, city, val),
+			City:            string(directionCity),
+			Direction:       string(oppositeDirection),
+			ConflictingCity: string(val),
+		}
+	}
+
+	// A road declared again must keep its kind
+	if ok {
+		kind, declared := roadKind(city, directionCity, false), roadKind(city, directionCity, oneWay)
+		switch {
+		case existing.blocked:
+			kind = roadKind(directionCity, city, true)
+		case wm.IsOneWay(city, direction):
+			kind = roadKind(city, directionCity, true)
+		}
+		if kind != declared {
+			return &wmerror.MapError{
+				Err:             wmerror.ErrInvalidDirection,
+				Description:     fmt.Sprintf("road (%v) of city (%v) is already %v", direction, city, kind),
+				City:            string(city),
+				Direction:       string(direction),
+				ConflictingCity: string(directionCity),
+			}
+		}
+		if cost := int(existing.cost); cost != r.Cost {
+			return &wmerror.MapError{
+				Err:             wmerror.ErrInvalidDirection,
+				Description:     fmt.Sprintf("road (%v) of city (%v) already costs (%v)", direction, city, cost),
+				City:            string(city),
+				Direction:       string(direction),
+				ConflictingCity: string(directionCity),
+			}
+		}
 	}
 
+	// Add both cities to WorldMap
+	from := wm.addCity(city)
+	to := wm.addCity(directionCity)
+
 	/*
 	 * Add direction to both cities (`city` and `directionCity`)
 	 * i.e
 	 * 		if foo's south is baz
 	 * 		implies baz's north is foo
 	 */
-	wm.cities[city][direction] = directionCity
-	wm.cities[directionCity][oppositeDirection] = city
+	fromTrapped, toTrapped := wm.isTrapped(from), wm.isTrapped(to)
+	d := wm.directions.index[direction]
+	*wm.road(from, d) = road{to: to, cost: int32(r.Cost)}
+	*wm.road(to, wm.directions.opposite[d]) = road{to: from, cost: int32(r.Cost), blocked: oneWay}
+	wm.updateTrapped(from, fromTrapped)
+	wm.updateTrapped(to, toTrapped)
 
 	return nil
 }
 
-// PrintWorldMap prints the world map in the same format as the input file.
-func (wm *WorldMap) Print() {
-	var out string
-	for city, directionEntry := range wm.cities {
-		out += fmt.Sprintf("%v:", city)
-		for direction, directionCity := range directionEntry {
-			out += fmt.Sprintf(" %v=%v", direction, directionCity)
+// GetDirectionCity returns the city the direction of the city leads to
+func (wm *WorldMap) GetDirectionCity(c City, d Direction) (City, bool) {
+	if r, ok := wm.lookup(c, d); ok {
+		return wm.cityNames[r.to], true
+	}
+	return "", false
+}
+
+// GetRoadCost returns the number of moves it takes
+// to travel the direction of the city
+func (wm *WorldMap) GetRoadCost(c City, d Direction) int {
+	if r, ok := wm.lookup(c, d); ok {
+		return int(r.cost)
+	}
+	return 1
+}
+
+// roadKind describes a road between two cities
+func roadKind(from, to City, oneWay bool) string {
+	if oneWay {
+		return fmt.Sprintf("one-way from (%v) to (%v)", from, to)
+	}
+	return "two-way"
+}
+
+// IsOneWay checks if the direction of the city is a one-way road
+// leading out of the city
+func (wm *WorldMap) IsOneWay(c City, d Direction) bool {
+	r, ok := wm.lookup(c, d)
+	if !ok || r.blocked {
+		return false
+	}
+	return wm.road(r.to, wm.directions.opposite[wm.directions.index[d]]).blocked
+}
+
+// WriteTo writes the world map to w in the same format
+// as the input file, so the output can be read back by Decode(r, FormatText).
+// InitWorldMap rejects the cities left without roads by destroyed cities.
+// Cities are written in the order they were added and directions
+// in the order of the direction system.
+// One-way roads are only written by the city they leave (e.g Foo north->Bar).
+// Roads taking more than one move are written with their cost (e.g north=Bar:3)
+// and coordinates follow the city (e.g Foo @3,4).
+// The output is buffered and streamed line by line, so writing
+// a large world does not build it in memory.
+func (wm *WorldMap) WriteTo(w io.Writer) (int64, error) {
+	counter := &countingWriter{w: w}
+	buf := bufio.NewWriter(counter)
+	for id, city := range wm.cityNames {
+		if wm.destroyed[id] {
+			continue
+		}
+		buf.WriteString(quote(city))
+		if coordinates, ok := wm.coordinates[cityID(id)]; ok {
+			buf.WriteString(" @")
+			buf.WriteString(coordinates.String())
+		}
+		for d, r := range wm.cityRoads(cityID(id)) {
+			if !r.open() {
+				continue
+			}
+			buf.WriteByte(' ')
+			buf.WriteString(string(wm.directions.directions[d]))
+			if wm.road(r.to, wm.directions.opposite[d]).blocked {
+				buf.WriteString("->")
+			} else {
+				buf.WriteByte('=')
+			}
+			buf.WriteString(quote(wm.cityNames[r.to]))
+			if r.cost != 1 {
+				buf.WriteByte(':')
+				buf.WriteString(strconv.Itoa(int(r.cost)))
+			}
+		}
+		if err := buf.WriteByte('\n'); err != nil {
+			return counter.n, err
 		}
-		out += "\n"
 	}
-	fmt.Print(out)
+	err := buf.Flush()
+	return counter.n, err
 }
 
-// GetCities returns the list of cities
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// Print prints the world map in the same format as the input file.
+// It returns the error writing to stdout, if any.
+func (wm *WorldMap) Print() error {
+	_, err := wm.WriteTo(os.Stdout)
+	return err
+}
+
+// GetCities returns the list of cities in the order they were added
 func (wm *WorldMap) GetCities() (cities []City) {
-	for city := range wm.cities {
-		cities = append(cities, city)
+	if wm.cityCount == 0 {
+		return nil
+	}
+	cities = make([]City, 0, wm.cityCount)
+	for id, city := range wm.cityNames {
+		if !wm.destroyed[id] {
+			cities = append(cities, city)
+		}
 	}
 	return
 }
 
-// GetCities returns the list of cities
+// GetCityDirections returns the directions leading out of the city
+// in the order of the direction system.
+// The reverse side of one-way roads is not a way out.
 func (wm *WorldMap) GetCityDirections(c City) (directions []Direction) {
-	for direction := range wm.cities[c] {
-		directions = append(directions, direction)
+	id, ok := wm.cityIDs[c]
+	if !ok {
+		return nil
+	}
+	for d, r := range wm.cityRoads(id) {
+		if r.open() {
+			directions = append(directions, wm.directions.directions[d])
+		}
 	}
 	return
 }
 
 // GetConnectedCities returns the list of connected cities
-// with the input city
+// with the input city in the order of the direction system
 func (wm *WorldMap) GetConnectedCities(c City) (cities []City) {
-	for _, city := range wm.cities[c] {
-		cities = append(cities, city)
+	id, ok := wm.cityIDs[c]
+	if !ok {
+		return nil
+	}
+	for _, r := range wm.cityRoads(id) {
+		if r.open() {
+			cities = append(cities, wm.cityNames[r.to])
+		}
 	}
 	return
 }
 
-// GetAlienList returns the list of aliens
+// isTrapped checks if no road leads out of the city
+func (wm *WorldMap) isTrapped(id cityID) bool {
+	for _, r := range wm.cityRoads(id) {
+		if r.open() {
+			return false
+		}
+	}
+	return true
+}
+
+// GetAlienList returns the list of aliens in the order they were unleashed
 func (wm *WorldMap) GetAlienList() (aliens []Alien) {
-	for alien := range wm.aliens {
-		aliens = append(aliens, alien)
+	if wm.alienCount == 0 {
+		return nil
+	}
+	aliens = make([]Alien, 0, wm.alienCount)
+	for id, alien := range wm.alienNames {
+		if wm.alienCities[id] != noCity {
+			aliens = append(aliens, alien)
+		}
 	}
 	return
 }
 
-// GetAliens returns the aliens from WorldMap
+// GetAliens returns a copy of the alien placements from WorldMap
 func (wm *WorldMap) GetAliens() map[Alien]City {
-	return wm.aliens
+	aliens := make(map[Alien]City, wm.alienCount)
+	for alien, id := range wm.alienIDs {
+		aliens[alien] = wm.cityNames[wm.alienCities[id]]
+	}
+	return aliens
+}
+
+// GetAlienCity returns the city of the alien,
+// or the city it is travelling to
+func (wm *WorldMap) GetAlienCity(a Alien) (City, bool) {
+	id, ok := wm.alienIDs[a]
+	if !ok {
+		return "", false
+	}
+	return wm.cityNames[wm.alienCities[id]], true
+}
+
+// GetAlienTransit returns the number of moves left
+// before the alien reaches its city, 0 if it is in the city
+func (wm *WorldMap) GetAlienTransit(a Alien) int {
+	id, ok := wm.alienIDs[a]
+	if !ok {
+		return 0
+	}
+	return int(wm.transit[id])
 }
 
 // GetTrappedAliens returns the list of trapped aliens
+// Aliens travelling are not trapped.
 func (wm *WorldMap) GetTrappedAliens() (trappedAliens []Alien) {
-	for alien, city := range wm.aliens {
-		if direction := wm.GetCityDirections(city); direction == nil {
-			trappedAliens = append(trappedAliens, alien)
-		}
+	ids := make([]alienID, 0, wm.trapped)
+	for _, city := range wm.trappedCities.ids {
+		ids = wm.appendList(ids, wm.residents[city])
 	}
-	return
+	return wm.alienNamesOf(ids)
 }
 
 // GetTrappedAlienCount returns the count of trapped aliens
 func (wm *WorldMap) GetTrappedAlienCount() (trappedAliens uint) {
-	for _, city := range wm.aliens {
-		if direction := wm.GetCityDirections(city); direction == nil {
-			trappedAliens++
+	return uint(wm.trapped)
+}
+
+// AddAlien places the alien in the city
+func (wm *WorldMap) AddAlien(a Alien, c City) error {
+	if a == "" {
+		return &wmerror.MapError{Err: wmerror.ErrInvalidAlien, Description: "empty alien name", City: string(c)}
+	}
+	if _, ok := wm.alienIDs[a]; ok {
+		return &wmerror.MapError{
+			Err:         wmerror.ErrInvalidAlien,
+			Description: fmt.Sprintf("duplicate alien (%v)", a),
+			City:        string(c),
+			Alien:       string(a),
 		}
 	}
-	return
+	city, ok := wm.cityIDs[c]
+	if !ok {
+		return &wmerror.MapError{
+			Err:         wmerror.ErrInvalidCity,
+			Description: fmt.Sprintf("unknown city (%v) for alien (%v)", c, a),
+			City:        string(c),
+			Alien:       string(a),
+		}
+	}
+	wm.addAlien(a, city)
+	return nil
+}
+
+// addAlien interns the alien, placing it in the city
+func (wm *WorldMap) addAlien(a Alien, city cityID) alienID {
+	id := alienID(len(wm.alienNames))
+	wm.alienIDs[a] = id
+	wm.alienNames = append(wm.alienNames, a)
+	wm.alienCities = append(wm.alienCities, city)
+	wm.transit = append(wm.transit, 0)
+	wm.strategies = append(wm.strategies, nil)
+	wm.headings = append(wm.headings, -1)
+	wm.next = append(wm.next, noAlien)
+	wm.prev = append(wm.prev, noAlien)
+	wm.arrive(id, city)
+	wm.alienCount++
+	return id
 }
 
 // UnleaseAliens unleases N aliens in the WorldMap
-func (wm *WorldMap) UnleaseNAliens(aliens uint) {
-	cities := wm.GetCities()
-	for i := uint(0); i < aliens; i++ {
-		random := rand.Intn(len(wm.cities))
-		name := Alien(fmt.Sprintf("alien-%v", i))
-		wm.aliens[name] = cities[random]
+// and returns the unleashed aliens.
+// Names of aliens already in the WorldMap are skipped
+func (wm *WorldMap) UnleaseNAliens(aliens uint) (unleashed []Alien) {
+	// Draw among the remaining cities, in the order they were added
+	var cities []cityID
+	if wm.cityCount != len(wm.cityNames) {
+		cities = make([]cityID, 0, wm.cityCount)
+		for id := range wm.cityNames {
+			if !wm.destroyed[id] {
+				cities = append(cities, cityID(id))
+			}
+		}
 	}
+	unleashed = make([]Alien, 0, aliens)
+	for i := uint(0); uint(len(unleashed)) < aliens; i++ {
+		name := Alien("alien-" + strconv.FormatUint(uint64(i), 10))
+		if _, ok := wm.alienIDs[name]; ok {
+			continue
+		}
+		city := cityID(wm.rand.Intn(wm.cityCount))
+		if cities != nil {
+			city = cities[city]
+		}
+		wm.addAlien(name, city)
+		unleashed = append(unleashed, name)
+	}
+	return
 }
 
 // RandWalkAlien moves the alien to random connected city
-func (wm *WorldMap) RandWalkAlien() {
-	for alien, city := range wm.GetAliens() {
-		connectedCities := wm.GetConnectedCities(city)
-		if connectedCities != nil {
-			random := rand.Intn(len(connectedCities))
-			wm.aliens[alien] = connectedCities[random]
+// and returns the moves made. Trapped aliens do not move.
+// Aliens with a strategy (see SetAlienStrategy) go where it chooses.
+// Aliens travelling a road costing N moves leave their city
+// on the first move and reach the next city N-1 moves later.
+func (wm *WorldMap) RandWalkAlien() (moves []Move) {
+	exits := make([]int32, 0, len(wm.directions.directions))
+	for id, city := range wm.alienCities {
+		if city == noCity {
+			continue
+		}
+		if wm.transit[id] > 0 {
+			wm.advanceTransit(alienID(id))
+			continue
+		}
+		exits = exits[:0]
+		for d, r := range wm.cityRoads(city) {
+			if r.open() {
+				exits = append(exits, int32(d))
+			}
+		}
+		if len(exits) > 0 {
+			var i int
+			if wm.strategies[id] == nil {
+				i = wm.rand.Intn(len(exits))
+			} else if i = wm.choose(alienID(id), exits, wm.rand); i < 0 {
+				continue
+			}
+			to := wm.travel(alienID(id), city, int(exits[i]))
+			if moves == nil {
+				moves = make([]Move, 0, wm.alienCount)
+			}
+			moves = append(moves, Move{Alien: wm.alienNames[id], From: wm.cityNames[city], To: wm.cityNames[to]})
+		}
+	}
+	return
+}
+
+// AdvanceTransit brings travelling aliens one move closer to their city,
+// as RandWalkAlien does, e.g before replaying the moves with MoveAlien
+func (wm *WorldMap) AdvanceTransit() {
+	if wm.travelling == 0 {
+		return
+	}
+	for id, city := range wm.alienCities {
+		if city != noCity && wm.transit[id] > 0 {
+			wm.advanceTransit(alienID(id))
+		}
+	}
+}
+
+func (wm *WorldMap) advanceTransit(id alienID) {
+	if wm.transit[id]--; wm.transit[id] == 0 {
+		wm.land(id)
+	}
+}
+
+// travel sends the alien down the d-th direction of the city
+// and returns the city it is going to
+func (wm *WorldMap) travel(id alienID, city cityID, d int) cityID {
+	r := wm.road(city, d)
+	wm.headings[id] = int32(d)
+	wm.leave(id)
+	if r.cost > 1 {
+		wm.depart(id, r.to, r.cost-1)
+	} else {
+		wm.arrive(id, r.to)
+	}
+	return r.to
+}
+
+// MoveAlien moves the alien to a city connected with its current city
+// Like in RandWalkAlien, the alien travels for the cost of the road.
+func (wm *WorldMap) MoveAlien(a Alien, c City) error {
+	id, ok := wm.alienIDs[a]
+	if !ok {
+		return &wmerror.MapError{
+			Err:         wmerror.ErrInvalidAlien,
+			Description: fmt.Sprintf("unknown alien (%v)", a),
+			Alien:       string(a),
+		}
+	}
+	city := wm.alienCities[id]
+	if wm.transit[id] > 0 {
+		return &wmerror.MapError{
+			Err:         wmerror.ErrInvalidAlien,
+			Description: fmt.Sprintf("alien (%v) is travelling to (%v)", a, wm.cityNames[city]),
+			City:        string(wm.cityNames[city]),
+			Alien:       string(a),
+		}
+	}
+	if to, ok := wm.cityIDs[c]; ok {
+		for d, r := range wm.cityRoads(city) {
+			if r.open() && r.to == to {
+				wm.travel(id, city, d)
+				return nil
+			}
 		}
 	}
+	return &wmerror.MapError{
+		Err:         wmerror.ErrInvalidCity,
+		Description: fmt.Sprintf("no road from (%v) to (%v) for alien (%v)", wm.cityNames[city], c, a),
+		City:        string(c),
+		Alien:       string(a),
+	}
 }
 
 // GetAliensByCity returns aliens by city
+// Aliens of a city are listed in the order they were unleashed,
+// aliens travelling to a city are not in it yet.
 func (wm *WorldMap) GetAliensByCity() map[City][]Alien {
 	aliensByCity := make(map[City][]Alien)
-	for alien, city := range wm.aliens {
-		if _, ok := aliensByCity[city]; !ok {
-			aliensByCity[city] = make([]Alien, 0)
+	for id, city := range wm.alienCities {
+		if city == noCity || wm.transit[id] > 0 {
+			continue
 		}
-		aliensByCity[city] = append(aliensByCity[city], alien)
+		name := wm.cityNames[city]
+		aliensByCity[name] = append(aliensByCity[name], wm.alienNames[id])
 	}
 	return aliensByCity
 }
 
+// GetOccupiedCities returns the cities with aliens in them
+// in the order they were added
+func (wm *WorldMap) GetOccupiedCities() (cities []City) {
+	ids := make([]cityID, 0, wm.alienCount)
+	for id, city := range wm.alienCities {
+		if city != noCity && wm.transit[id] == 0 && !wm.destroyed[city] {
+			ids = append(ids, city)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for i, id := range ids {
+		if i == 0 || id != ids[i-1] {
+			cities = append(cities, wm.cityNames[id])
+		}
+	}
+	return
+}
+
+// GetAliensTravellingTo returns the aliens travelling to the city
+// in the order they were unleashed
+func (wm *WorldMap) GetAliensTravellingTo(c City) (aliens []Alien) {
+	to, ok := wm.cityIDs[c]
+	if !ok {
+		return nil
+	}
+	return wm.alienNamesOf(wm.appendList(nil, wm.inbound[to]))
+}
+
 // DestroyCity removes the city from WorldMap
-// Also removes direction leading in or out
+// Also removes direction leading in or out, one-way roads included
 func (wm *WorldMap) DestroyCity(c City) {
-	for direction, city := range wm.cities[c] {
-		oppositeDirection, err := direction.GetOpposite()
-		if err != nil {
-			panic(err)
+	id, ok := wm.cityIDs[c]
+	if !ok {
+		return
+	}
+	wasTrapped := wm.isTrapped(id)
+	roads := wm.cityRoads(id)
+	for d, r := range roads {
+		if r.to != noCity {
+			neighbourTrapped := wm.isTrapped(r.to)
+			*wm.road(r.to, wm.directions.opposite[d]) = noRoad
+			roads[d] = noRoad
+			wm.updateTrapped(r.to, neighbourTrapped)
 		}
-		delete(wm.cities[city], oppositeDirection)
 	}
-	delete(wm.cities, c)
+	wm.updateTrapped(id, wasTrapped)
+	delete(wm.cityIDs, c)
+	delete(wm.coordinates, id)
+	wm.destroyed[id] = true
+	wm.cityCount--
 }
 
 // KillAliens removes the aliens from WorldMap
 func (wm *WorldMap) KillAliens(aliens []Alien) {
 	for _, alien := range aliens {
-		delete(wm.aliens, alien)
+		id, ok := wm.alienIDs[alien]
+		if !ok {
+			continue
+		}
+		if wm.transit[id] > 0 {
+			wm.unlink(&wm.inbound[wm.alienCities[id]], id)
+			wm.transit[id] = 0
+			wm.travelling--
+		} else {
+			wm.leave(id)
+		}
+		delete(wm.alienIDs, alien)
+		wm.alienCities[id] = noCity
+		wm.alienCount--
 	}
 }
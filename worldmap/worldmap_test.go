@@ -1,11 +1,18 @@
 package worldmap_test
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"strconv"
 	"strings"
 	"testing"
 
+	wmerror "github.com/harry-hov/alien-invasion/error"
 	"github.com/harry-hov/alien-invasion/worldmap"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -68,6 +75,55 @@ func TestAppendCityDirection(t *testing.T) {
 	worldMap.AddCity("Foo")
 	assert.NotNil(t, worldMap.AppendCityDirection("Foo", "Foo", worldmap.North))
 	assert.Nil(t, worldMap.AppendCityDirection("Foo", "Bar", worldmap.North))
+
+	// Ambiguous direction reports the conflicting city
+	err := worldMap.AppendCityDirection("Foo", "Baz", worldmap.North)
+	assert.True(t, errors.Is(err, wmerror.ErrInvalidDirection))
+	var mapErr *wmerror.MapError
+	assert.True(t, errors.As(err, &mapErr))
+	assert.Equal(t, "Foo", mapErr.City)
+	assert.Equal(t, "north", mapErr.Direction)
+	assert.Equal(t, "Bar", mapErr.ConflictingCity)
+}
+
+func TestWriteTo(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	n, err := worldMap.WriteTo(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	expected := `Foo north=Bar south=Qu-ux west=Baz
+Bar south=Foo west=Bee
+Baz east=Foo
+Qu-ux north=Foo
+Bee east=Bar
+`
+	assert.Equal(t, expected, buf.String())
+
+	// Output can be read back
+	reread, err := worldmap.InitWorldMap(&buf)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, worldMap.GetCities(), reread.GetCities())
+	for _, city := range worldMap.GetCities() {
+		assert.Equal(t, worldMap.GetConnectedCities(city), reread.GetConnectedCities(city))
+	}
+
+	// Even once cities lost all their roads
+	worldMap, err = worldmap.InitWorldMap(strings.NewReader(`Foo north=Bar west=Baz`))
+	require.Nil(t, err)
+	worldMap.DestroyCity("Foo")
+	buf.Reset()
+	_, err = worldMap.WriteTo(&buf)
+	require.Nil(t, err)
+	assert.Equal(t, "Bar\nBaz\n", buf.String())
+	_, err = worldmap.InitWorldMap(bytes.NewReader(buf.Bytes()))
+	assert.NotNil(t, err)
+	reread, err = worldmap.Decode(&buf, worldmap.FormatText)
+	require.Nil(t, err)
+	assert.Equal(t, []worldmap.City{"Bar", "Baz"}, reread.GetCities())
+	assert.Nil(t, reread.GetConnectedCities("Bar"))
 }
 
 func TestGetCities(t *testing.T) {
@@ -77,6 +133,17 @@ func TestGetCities(t *testing.T) {
 	assert.Equal(t, 1, len(worldMap.GetCities()))
 }
 
+func TestGetCitiesOrder(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	assert.Nil(t, err)
+	expected := []worldmap.City{"Foo", "Bar", "Baz", "Qu-ux", "Bee"}
+	assert.Equal(t, expected, worldMap.GetCities())
+	worldMap.DestroyCity("Baz")
+	expected = []worldmap.City{"Foo", "Bar", "Qu-ux", "Bee"}
+	assert.Equal(t, expected, worldMap.GetCities())
+	assert.Equal(t, []worldmap.City{"Bar", "Qu-ux"}, worldMap.GetConnectedCities("Foo"))
+}
+
 func TestGetCityDirections(t *testing.T) {
 	worldMap := worldmap.New()
 	worldMap.AddCity("Foo")
@@ -104,6 +171,20 @@ func TestDestroyCity(t *testing.T) {
 	assert.Equal(t, 4, len(worldMap.GetCities()))
 }
 
+func TestAddAlien(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	assert.Nil(t, err)
+	assert.Nil(t, worldMap.AddAlien("alien-0", "Foo"))
+	assert.NotNil(t, worldMap.AddAlien("alien-0", "Bar"))
+	assert.NotNil(t, worldMap.AddAlien("alien-1", "Unknown"))
+	assert.NotNil(t, worldMap.AddAlien("", "Foo"))
+
+	// Unleashed aliens do not replace placed aliens
+	worldMap.UnleaseNAliens(2)
+	assert.Equal(t, []worldmap.Alien{"alien-0", "alien-1", "alien-2"}, worldMap.GetAlienList())
+	assert.Equal(t, worldmap.City("Foo"), worldMap.GetAliens()["alien-0"])
+}
+
 func TestKillAliens(t *testing.T) {
 	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
 	assert.Nil(t, err)
@@ -112,3 +193,227 @@ func TestKillAliens(t *testing.T) {
 	assert.NotPanics(t, func() { worldMap.KillAliens([]worldmap.Alien{"alien-0", "alien-1"}) })
 	assert.Equal(t, 6, len(worldMap.GetAlienList()))
 }
+
+func TestClone(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	assert.Nil(t, err)
+	assert.Nil(t, worldMap.AddAlien("alien-0", "Foo"))
+
+	clone := worldMap.Clone()
+	assert.Equal(t, worldMap.GetCities(), clone.GetCities())
+	assert.Equal(t, worldMap.GetConnectedCities("Foo"), clone.GetConnectedCities("Foo"))
+	assert.Equal(t, worldMap.GetAliens(), clone.GetAliens())
+
+	// Changes to the clone do not affect the original
+	clone.DestroyCity("Foo")
+	clone.KillAliens([]worldmap.Alien{"alien-0"})
+	assert.Nil(t, clone.AddAlien("alien-1", "Bar"))
+	assert.Equal(t, 5, len(worldMap.GetCities()))
+	assert.Equal(t, []worldmap.City{"Bar", "Qu-ux", "Baz"}, worldMap.GetConnectedCities("Foo"))
+	assert.Equal(t, []worldmap.City{"Foo", "Bee"}, worldMap.GetConnectedCities("Bar"))
+	assert.Equal(t, []worldmap.Alien{"alien-0"}, worldMap.GetAlienList())
+}
+
+func TestGetAliensCopy(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	assert.Nil(t, err)
+	assert.Nil(t, worldMap.AddAlien("alien-0", "Foo"))
+
+	aliens := worldMap.GetAliens()
+	aliens["alien-0"] = "Bar"
+	delete(aliens, "alien-0")
+	city, ok := worldMap.GetAlienCity("alien-0")
+	assert.True(t, ok)
+	assert.Equal(t, worldmap.City("Foo"), city)
+}
+
+func TestMoveAlien(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	assert.Nil(t, err)
+	assert.Nil(t, worldMap.AddAlien("alien-0", "Foo"))
+	assert.Nil(t, worldMap.MoveAlien("alien-0", "Bar"))
+	assert.NotNil(t, worldMap.MoveAlien("alien-0", "Baz"))
+	assert.NotNil(t, worldMap.MoveAlien("alien-1", "Foo"))
+	city, _ := worldMap.GetAlienCity("alien-0")
+	assert.Equal(t, worldmap.City("Bar"), city)
+}
+
+func TestOneWayRoads(t *testing.T) {
+	const input string = `Foo north->Bar west=Baz
+Bar west->Bee`
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(input))
+	assert.Nil(t, err)
+	assert.True(t, worldMap.IsOneWay("Foo", worldmap.North))
+	assert.False(t, worldMap.IsOneWay("Bar", worldmap.South))
+	assert.False(t, worldMap.IsOneWay("Foo", worldmap.West))
+
+	// Aliens can only travel one-way roads forward
+	assert.Equal(t, []worldmap.City{"Bar", "Baz"}, worldMap.GetConnectedCities("Foo"))
+	assert.Equal(t, []worldmap.City{"Bee"}, worldMap.GetConnectedCities("Bar"))
+	assert.Nil(t, worldMap.GetConnectedCities("Bee"))
+	assert.Nil(t, worldMap.AddAlien("alien-0", "Bee"))
+	assert.Nil(t, worldMap.AddAlien("alien-1", "Bar"))
+	assert.Equal(t, []worldmap.Alien{"alien-0"}, worldMap.GetTrappedAliens())
+	assert.NotNil(t, worldMap.MoveAlien("alien-1", "Foo"))
+	assert.Equal(t, []worldmap.Move{{Alien: "alien-1", From: "Bar", To: "Bee"}}, worldMap.RandWalkAlien())
+
+	// Reverse side of a one-way road still takes the direction
+	assert.NotNil(t, worldMap.AppendCityDirection("Bar", "Qux", worldmap.South))
+	// Roads cannot change kind
+	assert.NotNil(t, worldMap.AppendCityDirection("Foo", "Bar", worldmap.North))
+	assert.NotNil(t, worldMap.AppendCityDirection("Bar", "Foo", worldmap.South))
+	assert.NotNil(t, worldMap.AppendOneWayCityDirection("Bar", "Foo", worldmap.South))
+	assert.NotNil(t, worldMap.AppendOneWayCityDirection("Foo", "Baz", worldmap.West))
+	assert.Nil(t, worldMap.AppendOneWayCityDirection("Foo", "Bar", worldmap.North))
+
+	var buf bytes.Buffer
+	_, err = worldMap.WriteTo(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "Foo north->Bar west=Baz\nBar west->Bee\nBaz east=Foo\nBee\n", buf.String())
+
+	// Destroying a city removes one-way roads leading in
+	worldMap.DestroyCity("Bee")
+	assert.Nil(t, worldMap.GetConnectedCities("Bar"))
+	worldMap.DestroyCity("Bar")
+	assert.Nil(t, worldMap.AppendCityDirection("Foo", "Bar", worldmap.North))
+	assert.False(t, worldMap.IsOneWay("Foo", worldmap.North))
+	assert.Equal(t, []worldmap.City{"Foo"}, worldMap.GetConnectedCities("Bar"))
+}
+
+func TestWeightedRoads(t *testing.T) {
+	const input string = `Foo north=Bar:3 west->Baz:2 south=Qu-ux`
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(input))
+	require.Nil(t, err)
+	assert.Equal(t, 3, worldMap.GetRoadCost("Foo", worldmap.North))
+	assert.Equal(t, 3, worldMap.GetRoadCost("Bar", worldmap.South))
+	assert.Equal(t, 2, worldMap.GetRoadCost("Foo", worldmap.West))
+	assert.Equal(t, 1, worldMap.GetRoadCost("Foo", worldmap.South))
+
+	var buf bytes.Buffer
+	_, err = worldMap.WriteTo(&buf)
+	require.Nil(t, err)
+	assert.Equal(t, "Foo north=Bar:3 south=Qu-ux west->Baz:2\nBar south=Foo:3\nBaz\nQu-ux north=Foo\n", buf.String())
+
+	// Roads cannot change cost
+	assert.NotNil(t, worldMap.AppendCityDirection("Bar", "Foo", worldmap.South))
+	assert.Nil(t, worldMap.AppendRoad("Bar", "Foo", worldmap.South, worldmap.Road{Cost: 3}))
+	assert.NotNil(t, worldMap.AppendRoad("Bar", "Bee", worldmap.West, worldmap.Road{Cost: -1}))
+
+	// Aliens travel the road for its cost and are not in a city meanwhile
+	require.Nil(t, worldMap.AddAlien("alien-0", "Foo"))
+	require.Nil(t, worldMap.AddAlien("alien-1", "Bar"))
+	require.Nil(t, worldMap.MoveAlien("alien-0", "Bar"))
+	assert.Equal(t, 2, worldMap.GetAlienTransit("alien-0"))
+	assert.NotNil(t, worldMap.MoveAlien("alien-0", "Foo"))
+	assert.Equal(t, []worldmap.Alien{"alien-0"}, worldMap.GetAliensTravellingTo("Bar"))
+	assert.Equal(t, map[worldmap.City][]worldmap.Alien{"Bar": {"alien-1"}}, worldMap.GetAliensByCity())
+
+	worldMap.KillAliens([]worldmap.Alien{"alien-1"})
+	worldMap.DestroyCity("Foo")
+	assert.Nil(t, worldMap.GetTrappedAliens())
+	worldMap.AdvanceTransit()
+	assert.Equal(t, 1, worldMap.GetAlienTransit("alien-0"))
+	assert.Nil(t, worldMap.RandWalkAlien())
+	assert.Equal(t, 0, worldMap.GetAlienTransit("alien-0"))
+	assert.Equal(t, []worldmap.Alien{"alien-0"}, worldMap.GetTrappedAliens())
+	assert.Equal(t, map[worldmap.City][]worldmap.Alien{"Bar": {"alien-0"}}, worldMap.GetAliensByCity())
+
+	for _, input := range []string{`Foo north=Bar:0`, `Foo north=Bar:x`, `Foo north=Bar:`, `Foo north=:3`, `Foo north=Bar:3
+Bar south=Foo`} {
+		_, err := worldmap.InitWorldMap(strings.NewReader(input))
+		assert.NotNil(t, err, input)
+	}
+}
+
+func TestInternedCities(t *testing.T) {
+	worldMap, err := worldmap.InitWorldMap(strings.NewReader(worldMapInput))
+	require.Nil(t, err)
+	assert.Equal(t, 5, worldMap.CityCount())
+	worldMap.UnleaseNAliens(4)
+	assert.Equal(t, 4, worldMap.AlienCount())
+
+	clone := worldMap.Clone()
+	worldMap.DestroyCity("Foo")
+	worldMap.KillAliens([]worldmap.Alien{"alien-0", "alien-0"})
+	assert.Equal(t, 4, worldMap.CityCount())
+	assert.Equal(t, 3, worldMap.AlienCount())
+	assert.Equal(t, []worldmap.Alien{"alien-1", "alien-2", "alien-3"}, worldMap.GetAlienList())
+	assert.Equal(t, 5, clone.CityCount())
+	assert.Equal(t, 4, clone.AlienCount())
+	assert.Equal(t, []worldmap.City{"Bar", "Baz", "Qu-ux", "Bee"}, worldMap.GetCities())
+
+	// A destroyed city added again comes last, without its roads
+	worldMap.AddCity("Foo")
+	assert.Equal(t, []worldmap.City{"Bar", "Baz", "Qu-ux", "Bee", "Foo"}, worldMap.GetCities())
+	assert.Nil(t, worldMap.GetCityDirections("Foo"))
+	assert.Equal(t, []worldmap.Direction{worldmap.West}, worldMap.GetCityDirections("Bar"))
+	assert.Equal(t, []worldmap.City{"Foo", "Bee"}, clone.GetConnectedCities("Bar"))
+
+	// Occupied cities are listed in the order they were added
+	require.Nil(t, worldMap.AddAlien("Bob", "Foo"))
+	require.Nil(t, worldMap.AddAlien("Eve", "Bee"))
+	require.Nil(t, worldMap.AddAlien("Kim", "Foo"))
+	worldMap.KillAliens(worldMap.GetAlienList()[:3])
+	assert.Equal(t, []worldmap.City{"Bee", "Foo"}, worldMap.GetOccupiedCities())
+
+	// Aliens are still unleashed in the remaining cities
+	aliens := worldMap.UnleaseNAliens(10)
+	assert.Equal(t, worldmap.Alien("alien-0"), aliens[0])
+	assert.Equal(t, 13, worldMap.AlienCount())
+	assert.Nil(t, worldmap.New().GetOccupiedCities())
+	for _, alien := range aliens {
+		city, ok := worldMap.GetAlienCity(alien)
+		assert.True(t, ok)
+		assert.Contains(t, worldMap.GetCities(), city)
+	}
+}
+
+// gridWorldMap returns a square grid of n*n cities
+func gridWorldMap(n int) *worldmap.WorldMap {
+	worldMap := worldmap.New()
+	name := func(x, y int) worldmap.City {
+		return worldmap.City("city-" + strconv.Itoa(y*n+x))
+	}
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			worldMap.AddCity(name(x, y))
+			if x > 0 {
+				if err := worldMap.AppendCityDirection(name(x, y), name(x-1, y), worldmap.West); err != nil {
+					panic(err)
+				}
+			}
+			if y > 0 {
+				if err := worldMap.AppendCityDirection(name(x, y), name(x, y-1), worldmap.South); err != nil {
+					panic(err)
+				}
+			}
+		}
+	}
+	return worldMap
+}
+
+func BenchmarkAppendRoad(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		gridWorldMap(1000)
+	}
+}
+
+func BenchmarkWriteTo(b *testing.B) {
+	worldMap := gridWorldMap(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := worldMap.WriteTo(io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRandWalkAlien(b *testing.B) {
+	worldMap := gridWorldMap(1000)
+	worldMap.SetRand(rand.New(rand.NewSource(1)))
+	worldMap.UnleaseNAliens(100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		worldMap.RandWalkAlien()
+	}
+}